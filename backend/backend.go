@@ -2,16 +2,46 @@ package backend
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type Backend struct {
 	*framework.Backend
-	lock   sync.RWMutex
-	client *client
+	lock     sync.RWMutex
+	provider provider
+
+	// getClientHook, when set, replaces the Confluent Cloud client built
+	// from the mount configuration. Tests use it to inject a fake.
+	getClientHook func(ctx context.Context, s logical.Storage) (cloudClient, error)
+
+	// libraryLock serializes check-out and check-in of library set keys.
+	libraryLock sync.Mutex
+
+	// oauthLock serializes changes to the OAuth signing keys.
+	oauthLock sync.Mutex
+
+	// rotationLock serializes rotations and revocations of lease keys.
+	rotationLock sync.Mutex
+
+	// roleHistoryLock serializes numbering and recording role versions.
+	roleHistoryLock sync.Mutex
+
+	// approvalLock serializes approving and using approval requests.
+	approvalLock sync.Mutex
+
+	// metricsLock guards activeKeys, the number of leased API keys per role
+	// last reported by refreshActiveKeys.
+	metricsLock sync.Mutex
+	activeKeys  map[string]int
+
+	// debug mirrors the debug setting of the mount configuration.
+	debug atomic.Bool
 }
 
 const backendHelp = `
@@ -30,20 +60,42 @@ func New() *Backend {
 			SealWrapStorage: []string{
 				"config",
 				"role/*",
+				"role-history/*",
+				"library/*",
+				"static-creds/*",
+				"oauth/keys",
+			},
+			Unauthenticated: []string{
+				"oauth/.well-known/jwks.json",
 			},
 		},
 		Paths: framework.PathAppend(
 			pathRole(&b),
+			pathRoleHistory(&b),
+			pathRolesBulk(&b),
+			pathBundles(&b),
+			pathRoleTemplates(&b),
+			pathLibrary(&b),
+			pathStaticCredentials(&b),
+			pathServiceAccounts(&b),
+			pathManagedServiceAccounts(&b),
+			pathOAuth(&b),
+			pathApprovals(&b),
 			[]*framework.Path{
 				pathConfig(&b),
 				pathCredentials(&b),
+				pathCredentialsRotate(&b),
+				pathImport(&b),
 			},
 		),
 		Secrets: []*framework.Secret{
 			b.confluentApiKey(),
+			b.confluentLibraryKey(),
+			b.confluentBundleKey(),
 		},
-		BackendType: logical.TypeLogical,
-		Invalidate:  b.invalidate,
+		BackendType:  logical.TypeLogical,
+		Invalidate:   b.invalidate,
+		PeriodicFunc: b.periodicFunc,
 	}
 	return &b
 }
@@ -62,19 +114,28 @@ func (b *Backend) invalidate(ctx context.Context, key string) {
 	}
 }
 
+func (b *Backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	return errors.Join(
+		b.rotateOAuthKeyIfDue(ctx, req),
+		b.deletePendingKeys(ctx, req),
+		b.deleteExpiredApprovals(ctx, req),
+		b.refreshActiveKeys(ctx, req),
+	)
+}
+
 func (b *Backend) reset() {
 	b.lock.Lock()
 	defer b.lock.Unlock()
-	b.client = nil
+	b.provider = nil
 }
 
-func (b *Backend) getClient(ctx context.Context, s logical.Storage) (*client, error) {
+func (b *Backend) getProvider(ctx context.Context, s logical.Storage) (provider, error) {
 	b.lock.RLock()
 	unlockFunc := b.lock.RUnlock
 	defer func() { unlockFunc() }()
 
-	if b.client != nil {
-		return b.client, nil
+	if b.provider != nil {
+		return b.provider, nil
 	}
 
 	b.lock.RUnlock()
@@ -89,11 +150,48 @@ func (b *Backend) getClient(ctx context.Context, s logical.Storage) (*client, er
 	if config == nil {
 		config = new(clientConfig)
 	}
+	b.debug.Store(config.Debug)
 
-	b.client, err = newClient(config)
+	b.provider, err = newProvider(config)
 	if err != nil {
 		return nil, err
 	}
 
-	return b.client, nil
+	return b.provider, nil
+}
+
+// getClient returns the Confluent Cloud client of the mount.
+func (b *Backend) getClient(ctx context.Context, s logical.Storage) (cloudClient, error) {
+	if b.getClientHook != nil {
+		c, err := b.getClientHook(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		return meteredClient{cloudClient: c, b: b}, nil
+	}
+
+	p, err := b.getProvider(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := p.(cloudClient)
+	if !ok {
+		return nil, fmt.Errorf("this operation requires connection_type %q, but the engine is configured for %q", connectionTypeCloud, p.connectionType())
+	}
+	return meteredClient{cloudClient: c, b: b}, nil
+}
+
+// getMDSClient returns the Confluent Platform client of the mount.
+func (b *Backend) getMDSClient(ctx context.Context, s logical.Storage) (*mdsClient, error) {
+	p, err := b.getProvider(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := p.(*mdsClient)
+	if !ok {
+		return nil, fmt.Errorf("this operation requires connection_type %q, but the engine is configured for %q", connectionTypePlatform, p.connectionType())
+	}
+	return c, nil
 }
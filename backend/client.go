@@ -1,17 +1,163 @@
 package backend
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	apikeysv2 "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
 	iamv2 "github.com/confluentinc/ccloud-sdk-go-v2/iam/v2"
+	"github.com/hashicorp/go-cleanhttp"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
 )
 
+const (
+	defaultConfluentURL = "https://api.confluent.cloud"
+
+	requestIDHeader = "X-Request-Id"
+
+	connectionTypeCloud    = "cloud"
+	connectionTypePlatform = "platform"
+)
+
+// provider is a Confluent deployment the engine manages credentials in:
+// Confluent Cloud through *client, or a self-managed Confluent Platform
+// through *mdsClient.
+type provider interface {
+	connectionType() string
+}
+
+// cloudClient is the Confluent Cloud API surface the engine uses. *client
+// implements it with the Confluent SDK, and tests substitute an in-memory
+// fake through Backend.getClientHook.
+type cloudClient interface {
+	provider
+
+	createToken(ctx context.Context, keySpec apiKeySpec) (*confluentApiKey, error)
+	getTokenSpec(ctx context.Context, apiKeyId string) (*apiKeySpec, error)
+	listTokens(ctx context.Context, owner string) ([]string, error)
+	verifyTokenSecret(ctx context.Context, apiKeyId, apiSecret string) error
+	deleteToken(ctx context.Context, apiKeyId string) error
+
+	listServiceAccounts(ctx context.Context) ([]iamv2.IamV2ServiceAccount, error)
+	getServiceAccount(ctx context.Context, id string) (*iamv2.IamV2ServiceAccount, error)
+	createServiceAccount(ctx context.Context, displayName, description string) (*iamv2.IamV2ServiceAccount, error)
+	updateServiceAccount(ctx context.Context, id, description string) error
+	deleteServiceAccount(ctx context.Context, id string) error
+
+	listUsers(ctx context.Context) ([]iamv2.IamV2User, error)
+	getUser(ctx context.Context, id string) (*iamv2.IamV2User, error)
+
+	listRoleBindings(ctx context.Context, principal, roleName, crnPattern string) ([]roleBinding, error)
+	createRoleBinding(ctx context.Context, binding roleBinding) (*roleBinding, error)
+	deleteRoleBinding(ctx context.Context, id string) error
+
+	// configuredOrganizationID returns the organization ID set in config, if
+	// any.
+	configuredOrganizationID() string
+
+	// do calls Confluent Cloud APIs that are not covered above.
+	do(ctx context.Context, method, path string, query neturl.Values, body, out interface{}) error
+}
+
+var _ cloudClient = (*client)(nil)
+
+// newProvider returns the provider for the configured connection type.
+func newProvider(config *clientConfig) (provider, error) {
+	if config == nil {
+		return nil, errors.New("client configuration was nil")
+	}
+
+	switch config.connectionType() {
+	case connectionTypeCloud:
+		return newClient(config)
+	case connectionTypePlatform:
+		return newMDSClient(config)
+	default:
+		return nil, fmt.Errorf("unsupported connection_type %q", config.ConnectionType)
+	}
+}
+
 type client struct {
 	iam     *iamv2.APIClient
 	apikeys *apikeysv2.APIClient
 
 	authContext func() context.Context
+
+	// baseURL, username and password are used for Confluent APIs that are
+	// not covered by the SDK modules.
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+
+	organizationID string
+}
+
+// confluentAPIError is returned when Confluent responds with an unsuccessful
+// status code, so callers can tell missing resources apart from failures.
+type confluentAPIError struct {
+	StatusCode int
+	Err        error
+
+	// RequestID is the X-Request-Id response header, which Confluent
+	// support needs to trace a failed request.
+	RequestID string
+}
+
+func (e *confluentAPIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("confluent API returned %d (request ID %s): %s", e.StatusCode, e.RequestID, e.Err)
+	}
+	return fmt.Sprintf("confluent API returned %d: %s", e.StatusCode, e.Err)
+}
+
+func (e *confluentAPIError) Unwrap() error {
+	return e.Err
+}
+
+// wrapAPIError attaches the HTTP status code of an SDK call to its error.
+func wrapAPIError(err error, httpResp *http.Response) error {
+	if err == nil || httpResp == nil {
+		return err
+	}
+	return &confluentAPIError{StatusCode: httpResp.StatusCode, Err: err, RequestID: httpResp.Header.Get(requestIDHeader)}
+}
+
+// requestID returns the Confluent request ID of a failed API call, if any.
+func requestID(err error) string {
+	var apiErr *confluentAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RequestID
+	}
+	return ""
+}
+
+func isNotFound(err error) bool {
+	var apiErr *confluentAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// isRetryable reports whether a failed call may succeed when retried: it was
+// rate limited, failed on Confluent's side or never got a response.
+func isRetryable(err error) bool {
+	var apiErr *confluentAPIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+}
+
+// isPermanent reports whether Confluent refused a call because of the
+// engine's credentials, which retrying cannot fix.
+func isPermanent(err error) bool {
+	var apiErr *confluentAPIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
 }
 
 func newClient(config *clientConfig) (*client, error) {
@@ -25,22 +171,100 @@ func newClient(config *clientConfig) (*client, error) {
 		return nil, errors.New("both username and password must be provided")
 	}
 
+	// Each SDK module looks up credentials under its own context key.
 	credentialHelper = func() context.Context {
-		return context.WithValue(context.Background(), apikeysv2.ContextBasicAuth, apikeysv2.BasicAuth{
+		ctx := context.WithValue(context.Background(), apikeysv2.ContextBasicAuth, apikeysv2.BasicAuth{
+			UserName: config.Username,
+			Password: config.Password,
+		})
+		return context.WithValue(ctx, iamv2.ContextBasicAuth, iamv2.BasicAuth{
 			UserName: config.Username,
 			Password: config.Password,
 		})
 	}
 
+	baseURL := defaultConfluentURL
+	iamConfig := iamv2.NewConfiguration()
+	apikeysConfig := apikeysv2.NewConfiguration()
 	if config.URL != "" {
-		// TODO: Override the URL
+		baseURL = strings.TrimSuffix(config.URL, "/")
+		iamConfig.Servers = iamv2.ServerConfigurations{{URL: baseURL}}
+		apikeysConfig.Servers = apikeysv2.ServerConfigurations{{URL: baseURL}}
 	}
 
 	c := &client{
-		iam:     iamv2.NewAPIClient(iamv2.NewConfiguration()),
-		apikeys: apikeysv2.NewAPIClient(apikeysv2.NewConfiguration()),
+		iam:     iamv2.NewAPIClient(iamConfig),
+		apikeys: apikeysv2.NewAPIClient(apikeysConfig),
 
 		authContext: credentialHelper,
+
+		baseURL:    baseURL,
+		username:   config.Username,
+		password:   config.Password,
+		httpClient: cleanhttp.DefaultPooledClient(),
+
+		organizationID: config.OrganizationID,
 	}
 	return c, nil
 }
+
+func (c *client) connectionType() string {
+	return connectionTypeCloud
+}
+
+func (c *client) configuredOrganizationID() string {
+	return c.organizationID
+}
+
+// do performs an authenticated request against a Confluent Cloud API that has
+// no SDK module available. The body, if any, is sent as JSON and the JSON
+// response is decoded into out.
+func (c *client) do(ctx context.Context, method, path string, query neturl.Values, body, out interface{}) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling Confluent API %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading Confluent API response for %s: %w", path, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return &confluentAPIError{
+			StatusCode: resp.StatusCode,
+			Err:        errors.New(strings.TrimSpace(string(respBody))),
+			RequestID:  resp.Header.Get(requestIDHeader),
+		}
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
@@ -5,12 +5,21 @@ import (
 	"errors"
 	"fmt"
 	v2 "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"net/http"
 )
 
 const (
 	ConfluentApiKeyType = "confluent_api_key"
+
+	serviceAccountOwnerKind = "service-account"
+	userOwnerKind           = "User"
+
+	schemaRegistryResourceKind = "SchemaRegistry"
+	flinkRegionResourceKind    = "Region"
+	ksqlDBResourceKind         = "Cluster"
 )
 
 type confluentApiKey struct {
@@ -36,12 +45,9 @@ func (b *Backend) confluentApiKey() *framework.Secret {
 	}
 }
 
+// apiKeyRevoke deletes the API key of a lease, along with any keys it was
+// rotated to. Failures are returned, so Vault retries the revocation.
 func (b *Backend) apiKeyRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	c, err := b.getClient(ctx, req.Storage)
-	if err != nil {
-		return nil, fmt.Errorf("error getting client: %w", err)
-	}
-
 	apiKeyId := ""
 	apiKeyIdValue, ok := req.Secret.InternalData["api_key"]
 	if ok {
@@ -51,28 +57,117 @@ func (b *Backend) apiKeyRevoke(ctx context.Context, req *logical.Request, d *fra
 		}
 	}
 
-	auth := c.authContext()
+	owner, _ := req.Secret.InternalData["owner"].(string)
+	resource, _ := req.Secret.InternalData["resource"].(string)
+	role, _ := req.Secret.InternalData["role"].(string)
+
+	if apiKeyId == "" {
+		b.logError("refusing to revoke lease without an API key ID", "role", role)
+		return nil, errors.New("secret internal data has no api_key to revoke")
+	}
+
+	if err := b.deleteAPIKey(ctx, req.Storage, role, apiKeyId); err != nil {
+		return nil, err
+	}
+
+	if err := b.revokeRotatedKeys(ctx, req.Storage, role, apiKeyId); err != nil {
+		return nil, err
+	}
+
+	b.credsRevoked(role)
+	b.sendEvent(ctx, req, eventCredsRevoke,
+		"role", role,
+		"api_key", apiKeyId,
+		"owner", owner,
+		"resource", resource)
+
+	return nil, nil
+}
+
+// deleteAPIKey deletes an API key, treating keys that were already deleted in
+// Confluent as revoked. Other failures are reported as temporary or, when
+// Confluent rejects the engine's credentials, as needing operator action.
+func (b *Backend) deleteAPIKey(ctx context.Context, s logical.Storage, role, apiKeyId string) error {
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	logArgs := []interface{}{"role", role, "api_key", apiKeyId}
+
+	err = c.deleteToken(ctx, apiKeyId)
+	switch {
+	case err == nil:
+		b.logInfo("revoked API key", logArgs...)
+	case isNotFound(err):
+		b.logWarn("API key was already deleted in Confluent, treating it as revoked", logArgs...)
+	case isPermanent(err):
+		b.logError("Confluent rejected revoking API key, check the credentials in config",
+			append(logArgs, errorLogArgs(err)...)...)
+		return fmt.Errorf("Confluent rejected revoking API key %q, retries will fail until the credentials in config are fixed: %w", apiKeyId, err)
+	case isRetryable(err):
+		b.logWarn("temporary error revoking API key, the revocation will be retried",
+			append(logArgs, errorLogArgs(err)...)...)
+		return fmt.Errorf("temporary error revoking API key %q: %w", apiKeyId, err)
+	default:
+		b.logError("error revoking API key", append(logArgs, errorLogArgs(err)...)...)
+		return err
+	}
+	return nil
+}
 
-	_, err = c.apikeys.APIKeysIamV2Api.DeleteIamV2ApiKey(auth, apiKeyId).Execute()
-	return nil, err
+// apiKeySpec describes the owner and, optionally, the resource an API key is
+// scoped to. Keys without a resource are Cloud API keys.
+type apiKeySpec struct {
+	Owner        string `json:"owner"`
+	OwnerKind    string `json:"owner_kind,omitempty"`
+	Resource     string `json:"resource,omitempty"`
+	ResourceKind string `json:"resource_kind,omitempty"`
+	Environment  string `json:"environment,omitempty"`
+	DisplayName  string `json:"display_name,omitempty"`
+	Description  string `json:"description,omitempty"`
 }
 
-func createToken(ctx context.Context, c *client, roleName string) (*confluentApiKey, error) {
-	ownerKind := "service-account"
+func (c *client) createToken(ctx context.Context, keySpec apiKeySpec) (*confluentApiKey, error) {
+	ownerKind := keySpec.OwnerKind
+	if ownerKind == "" {
+		ownerKind = serviceAccountOwnerKind
+	}
+
+	displayName := keySpec.DisplayName
+	if displayName == "" {
+		displayName = "Vault generated token"
+	}
+
+	description := keySpec.Description
+	if description == "" {
+		description = "Vault generated token"
+	}
+
 	spec := v2.NewIamV2ApiKeySpec()
-	spec.SetDisplayName("Vault generated token")
-	spec.SetDescription("Vault generated token")
-	spec.SetOwner(v2.ObjectReference{Id: roleName, Kind: &ownerKind})
+	spec.SetDisplayName(displayName)
+	spec.SetDescription(description)
+	spec.SetOwner(v2.ObjectReference{Id: keySpec.Owner, Kind: &ownerKind})
+	if keySpec.Resource != "" {
+		resource := v2.ObjectReference{Id: keySpec.Resource}
+		if keySpec.Environment != "" {
+			resource.SetEnvironment(keySpec.Environment)
+		}
+		if keySpec.ResourceKind != "" {
+			resource.SetKind(keySpec.ResourceKind)
+		}
+		spec.SetResource(resource)
+	}
 	createApiKeyRequest := v2.IamV2ApiKey{Spec: spec}
 	auth := c.authContext()
 
-	apiKey, _, err := c.apikeys.APIKeysIamV2Api.
+	apiKey, httpResp, err := c.apikeys.APIKeysIamV2Api.
 		CreateIamV2ApiKey(auth).
 		IamV2ApiKey(createApiKeyRequest).
 		Execute()
 
 	if err != nil {
-		return nil, fmt.Errorf("error creating Confluent API Key : %w", err)
+		return nil, fmt.Errorf("error creating Confluent API Key : %w", wrapAPIError(err, httpResp))
 	}
 
 	return &confluentApiKey{
@@ -81,6 +176,93 @@ func createToken(ctx context.Context, c *client, roleName string) (*confluentApi
 	}, nil
 }
 
+// getTokenSpec looks up an existing API key and returns its owner and resource.
+func (c *client) getTokenSpec(ctx context.Context, apiKeyId string) (*apiKeySpec, error) {
+	auth := c.authContext()
+
+	apiKey, httpResp, err := c.apikeys.APIKeysIamV2Api.GetIamV2ApiKey(auth, apiKeyId).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error reading Confluent API Key %q: %w", apiKeyId, wrapAPIError(err, httpResp))
+	}
+
+	spec := apiKey.GetSpec()
+	owner := spec.GetOwner()
+	keySpec := &apiKeySpec{
+		Owner:     owner.GetId(),
+		OwnerKind: owner.GetKind(),
+	}
+	if resource, ok := spec.GetResourceOk(); ok && resource != nil {
+		keySpec.Resource = resource.GetId()
+		keySpec.ResourceKind = resource.GetKind()
+		keySpec.Environment = resource.GetEnvironment()
+	}
+
+	return keySpec, nil
+}
+
+// drift describes how the role's target differs from the API key it issued,
+// or returns an empty string if the key still matches the role.
+func (r *confluentRoleEntry) drift(spec *apiKeySpec) string {
+	if r.credentialType() == credentialTypeUser {
+		if !strutil.StrListContains(r.AllowedUserIDs, spec.Owner) {
+			return fmt.Sprintf("user %q is no longer allowed by the role", spec.Owner)
+		}
+	} else if isIdentityTemplate(r.ServiceAccount) {
+		if !r.allowsServiceAccount(spec.Owner) {
+			return fmt.Sprintf("service account %q is no longer allowed by the role", spec.Owner)
+		}
+	} else if r.ServiceAccount != spec.Owner {
+		return fmt.Sprintf("the role's service account changed from %q to %q", spec.Owner, r.ServiceAccount)
+	}
+
+	if r.Resource != spec.Resource {
+		return fmt.Sprintf("the role's resource changed from %q to %q", spec.Resource, r.Resource)
+	}
+	return ""
+}
+
+// verifyTokenSecret checks the secret of a Cloud API key by authenticating
+// with it. Keys scoped to a resource cannot call the Cloud API, so their
+// secrets cannot be verified this way. Only a successful response, or 403 for
+// keys without IAM permissions, accepts the secret. Rate limits and failures
+// on Confluent's side return an error isRetryable reports as temporary.
+func (c *client) verifyTokenSecret(ctx context.Context, apiKeyId, apiSecret string) error {
+	auth := context.WithValue(context.Background(), v2.ContextBasicAuth, v2.BasicAuth{
+		UserName: apiKeyId,
+		Password: apiSecret,
+	})
+
+	_, httpResp, err := c.apikeys.APIKeysIamV2Api.GetIamV2ApiKey(auth, apiKeyId).Execute()
+	if httpResp == nil {
+		return fmt.Errorf("error verifying Confluent API Key %q: %w", apiKeyId, err)
+	}
+
+	switch {
+	case httpResp.StatusCode == http.StatusForbidden:
+		return nil
+	case httpResp.StatusCode >= 200 && httpResp.StatusCode < 300:
+		return nil
+	case httpResp.StatusCode == http.StatusUnauthorized:
+		return wrapAPIError(fmt.Errorf("API secret for key %q was rejected by Confluent", apiKeyId), httpResp)
+	}
+
+	if err == nil {
+		err = fmt.Errorf("unexpected response %q", httpResp.Status)
+	}
+	return fmt.Errorf("error verifying Confluent API Key %q: %w", apiKeyId, wrapAPIError(err, httpResp))
+}
+
+func (c *client) deleteToken(ctx context.Context, apiKeyId string) error {
+	auth := c.authContext()
+
+	httpResp, err := c.apikeys.APIKeysIamV2Api.DeleteIamV2ApiKey(auth, apiKeyId).Execute()
+	if err != nil {
+		return fmt.Errorf("error deleting Confluent API Key %q: %w", apiKeyId, wrapAPIError(err, httpResp))
+	}
+
+	return nil
+}
+
 func (b *Backend) tokenRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	roleRaw, ok := req.Secret.InternalData["role"]
 	if !ok {
@@ -97,14 +279,57 @@ func (b *Backend) tokenRenew(ctx context.Context, req *logical.Request, d *frame
 		return nil, errors.New("error retrieving role: role is nil")
 	}
 
-	resp := &logical.Response{Secret: req.Secret}
+	apiKeyId, _ := req.Secret.InternalData["api_key"].(string)
+	if apiKeyId == "" {
+		return nil, errors.New("secret internal data has no api_key to renew")
+	}
 
-	if roleEntry.TTL > 0 {
-		resp.Secret.TTL = roleEntry.TTL
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
 	}
-	if roleEntry.MaxTTL > 0 {
-		resp.Secret.MaxTTL = roleEntry.MaxTTL
+
+	if err := b.bindLeaseID(ctx, req.Storage, apiKeyId, req.Secret.LeaseID); err != nil {
+		return nil, fmt.Errorf("error binding lease to API key: %w", err)
 	}
 
+	// Rotated leases are backed by a newer key than the one they were issued
+	// with.
+	currentKeyId, err := currentLeaseKey(ctx, req.Storage, apiKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := c.getTokenSpec(ctx, currentKeyId)
+	if isNotFound(err) {
+		return logical.ErrorResponse("API key %q no longer exists in Confluent", currentKeyId), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if drift := roleEntry.drift(spec); drift != "" && !roleEntry.AllowRenewalOnDrift {
+		return logical.ErrorResponse("cannot renew API key %q: %s since it was issued; "+
+			"request new credentials, or set allow_renewal_on_drift on the role", currentKeyId, drift), nil
+	}
+
+	ttl, warnings, err := framework.CalculateTTL(b.System(), req.Secret.Increment, roleEntry.TTL, 0, roleEntry.MaxTTL, 0, req.Secret.IssueTime)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{Secret: req.Secret}
+	resp.Secret.TTL = ttl
+	resp.Secret.MaxTTL = roleEntry.MaxTTL
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+
+	b.credsRenewed(role)
+	b.logDebug("renewed API key lease",
+		"role", role,
+		"api_key", currentKeyId,
+		"ttl", resp.Secret.TTL.String())
+
 	return resp, nil
 }
@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyTokenSecret(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	c, err := newClient(&clientConfig{Username: username, Password: password, URL: srv.URL})
+	require.NoError(t, err)
+
+	for _, accepted := range []int{http.StatusOK, http.StatusForbidden} {
+		status = accepted
+		require.NoError(t, c.verifyTokenSecret(context.Background(), "KEY1", "secret"), accepted)
+	}
+
+	status = http.StatusUnauthorized
+	err = c.verifyTokenSecret(context.Background(), "KEY1", "secret")
+	require.ErrorContains(t, err, "rejected")
+	require.False(t, isRetryable(err))
+
+	for _, temporary := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		status = temporary
+		err = c.verifyTokenSecret(context.Background(), "KEY1", "secret")
+		require.Error(t, err, temporary)
+		require.True(t, isRetryable(err), temporary)
+	}
+
+	status = http.StatusNotFound
+	err = c.verifyTokenSecret(context.Background(), "KEY1", "secret")
+	require.Error(t, err)
+	require.False(t, isRetryable(err))
+}
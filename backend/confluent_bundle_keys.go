@@ -0,0 +1,171 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	ConfluentBundleKeyType = "confluent_api_key_bundle"
+)
+
+// bundleKey is an API key issued under a bundle lease.
+type bundleKey struct {
+	Role     string `json:"role"`
+	APIKey   string `json:"api_key"`
+	Owner    string `json:"owner,omitempty"`
+	Resource string `json:"resource,omitempty"`
+}
+
+func (b *Backend) confluentBundleKey() *framework.Secret {
+	return &framework.Secret{
+		Type: ConfluentBundleKeyType,
+		Fields: map[string]*framework.FieldSchema{
+			"credentials": {
+				Type:        framework.TypeMap,
+				Description: "Confluent API keys and secrets by role",
+			},
+		},
+		Revoke: b.bundleKeysRevoke,
+		Renew:  b.bundleKeysRenew,
+	}
+}
+
+// bundleFromSecret returns the bundle and the keys issued under the lease.
+func bundleFromSecret(req *logical.Request) (string, []bundleKey, error) {
+	name, _ := req.Secret.InternalData["bundle"].(string)
+	if name == "" {
+		return "", nil, errors.New("secret is missing bundle internal data")
+	}
+
+	// Internal data comes back from storage as generic JSON values.
+	raw, err := json.Marshal(req.Secret.InternalData["keys"])
+	if err != nil {
+		return "", nil, err
+	}
+
+	var keys []bundleKey
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return "", nil, fmt.Errorf("invalid value for keys in secret internal data: %w", err)
+	}
+
+	if len(keys) == 0 {
+		return "", nil, errors.New("secret internal data has no keys to revoke")
+	}
+	return name, keys, nil
+}
+
+// deleteBundleKeys deletes the keys of a bundle and their records, attempting
+// every key even if some cannot be deleted.
+func (b *Backend) deleteBundleKeys(ctx context.Context, req *logical.Request, keys []bundleKey) error {
+	var errs []error
+	for _, key := range keys {
+		if err := b.deleteAPIKey(ctx, req.Storage, key.Role, key.APIKey); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := req.Storage.Delete(ctx, leaseKeyStoragePrefix+key.APIKey); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *Backend) bundleKeysRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name, keys, err := bundleFromSecret(req)
+	if err != nil {
+		b.logError("refusing to revoke bundle lease", errorLogArgs(err)...)
+		return nil, err
+	}
+
+	// Keys already deleted by an earlier attempt are treated as revoked by
+	// deleteAPIKey, so the whole bundle is retried until every key is gone.
+	if err := b.deleteBundleKeys(ctx, req, keys); err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		b.credsRevoked(key.Role)
+		b.sendEvent(ctx, req, eventCredsRevoke,
+			"role", key.Role,
+			"bundle", name,
+			"api_key", key.APIKey,
+			"owner", key.Owner,
+			"resource", key.Resource)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) bundleKeysRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name, keys, err := bundleFromSecret(req)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := getBundle(ctx, req.Storage, name)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving bundle: %w", err)
+	}
+
+	if bundle == nil {
+		return logical.ErrorResponse("bundle %q no longer exists", name), nil
+	}
+
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	roles := make([]*confluentRoleEntry, 0, len(keys))
+	for _, key := range keys {
+		role, err := b.getRole(ctx, req.Storage, key.Role)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving role: %w", err)
+		}
+
+		if role == nil {
+			return logical.ErrorResponse("role %q of the bundle no longer exists", key.Role), nil
+		}
+
+		spec, err := c.getTokenSpec(ctx, key.APIKey)
+		if isNotFound(err) {
+			return logical.ErrorResponse("API key %q no longer exists in Confluent", key.APIKey), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if drift := role.drift(spec); drift != "" && !role.AllowRenewalOnDrift {
+			return logical.ErrorResponse("cannot renew API key %q of role %q: %s since it was issued; "+
+				"request new credentials, or set allow_renewal_on_drift on the role", key.APIKey, key.Role, drift), nil
+		}
+		roles = append(roles, role)
+	}
+
+	ttl, maxTTL := bundle.leaseTTLs(roles)
+	ttl, warnings, err := framework.CalculateTTL(b.System(), req.Secret.Increment, ttl, 0, maxTTL, 0, req.Secret.IssueTime)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{Secret: req.Secret}
+	resp.Secret.TTL = ttl
+	resp.Secret.MaxTTL = maxTTL
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+
+	for _, key := range keys {
+		b.credsRenewed(key.Role)
+	}
+	b.logDebug("renewed API key bundle lease",
+		"bundle", name,
+		"ttl", resp.Secret.TTL.String())
+
+	return resp, nil
+}
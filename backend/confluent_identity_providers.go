@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+)
+
+const (
+	identityProvidersPath = "/iam/v2/identity-providers"
+)
+
+// identityProvider is a Confluent OAuth/OIDC identity provider that trusts
+// tokens signed by keys published at its JWKS URI.
+type identityProvider struct {
+	ID          string `json:"id,omitempty"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	Issuer      string `json:"issuer,omitempty"`
+	JwksURI     string `json:"jwks_uri,omitempty"`
+}
+
+// identityPool maps tokens from an identity provider that match a filter
+// expression to a Confluent principal.
+type identityPool struct {
+	ID            string `json:"id,omitempty"`
+	DisplayName   string `json:"display_name"`
+	Description   string `json:"description"`
+	IdentityClaim string `json:"identity_claim"`
+	Filter        string `json:"filter"`
+}
+
+func identityPoolsPath(providerID string) string {
+	return identityProvidersPath + "/" + neturl.PathEscape(providerID) + "/identity-pools"
+}
+
+func createIdentityProvider(ctx context.Context, c cloudClient, provider identityProvider) (*identityProvider, error) {
+	created := new(identityProvider)
+	if err := c.do(ctx, http.MethodPost, identityProvidersPath, nil, provider, created); err != nil {
+		return nil, fmt.Errorf("error creating Confluent identity provider: %w", err)
+	}
+	return created, nil
+}
+
+func deleteIdentityProvider(ctx context.Context, c cloudClient, id string) error {
+	if err := c.do(ctx, http.MethodDelete, identityProvidersPath+"/"+neturl.PathEscape(id), nil, nil, nil); err != nil {
+		return fmt.Errorf("error deleting Confluent identity provider %q: %w", id, err)
+	}
+	return nil
+}
+
+func createIdentityPool(ctx context.Context, c cloudClient, providerID string, pool identityPool) (*identityPool, error) {
+	created := new(identityPool)
+	if err := c.do(ctx, http.MethodPost, identityPoolsPath(providerID), nil, pool, created); err != nil {
+		return nil, fmt.Errorf("error creating Confluent identity pool: %w", err)
+	}
+	return created, nil
+}
+
+func updateIdentityPool(ctx context.Context, c cloudClient, providerID string, pool identityPool) error {
+	path := identityPoolsPath(providerID) + "/" + neturl.PathEscape(pool.ID)
+	update := pool
+	update.ID = ""
+	if err := c.do(ctx, http.MethodPatch, path, nil, update, nil); err != nil {
+		return fmt.Errorf("error updating Confluent identity pool %q: %w", pool.ID, err)
+	}
+	return nil
+}
+
+func deleteIdentityPool(ctx context.Context, c cloudClient, providerID, id string) error {
+	path := identityPoolsPath(providerID) + "/" + neturl.PathEscape(id)
+	if err := c.do(ctx, http.MethodDelete, path, nil, nil, nil); err != nil {
+		return fmt.Errorf("error deleting Confluent identity pool %q: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	ConfluentLibraryKeyType = "confluent_library_key"
+)
+
+func (b *Backend) confluentLibraryKey() *framework.Secret {
+	return &framework.Secret{
+		Type: ConfluentLibraryKeyType,
+		Fields: map[string]*framework.FieldSchema{
+			"api_key": {
+				Type:        framework.TypeString,
+				Description: "Confluent API Key",
+			},
+			"api_secret": {
+				Type:        framework.TypeString,
+				Description: "Confluent API Secret",
+			},
+		},
+		Revoke: b.libraryKeyRevoke,
+		Renew:  b.libraryKeyRenew,
+	}
+}
+
+// libraryCheckOutFromSecret returns the library set and key referenced by the
+// secret, along with the check-out ID the lease was issued for.
+func libraryCheckOutFromSecret(req *logical.Request) (setName, apiKeyId, checkOutId string, err error) {
+	for key, dest := range map[string]*string{
+		"set":          &setName,
+		"api_key":      &apiKeyId,
+		"check_out_id": &checkOutId,
+	} {
+		raw, ok := req.Secret.InternalData[key]
+		if !ok {
+			return "", "", "", fmt.Errorf("secret is missing %s internal data", key)
+		}
+		if *dest, ok = raw.(string); !ok {
+			return "", "", "", fmt.Errorf("invalid value for %s in secret internal data", key)
+		}
+	}
+	return setName, apiKeyId, checkOutId, nil
+}
+
+func (b *Backend) libraryKeyRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	setName, apiKeyId, checkOutId, err := libraryCheckOutFromSecret(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, setName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The set, key or check-out may already be gone if the key was checked
+	// in explicitly before the lease expired.
+	if set == nil {
+		return nil, nil
+	}
+
+	key, ok := set.Keys[apiKeyId]
+	if !ok || key.CheckOut == nil || key.CheckOut.ID != checkOutId {
+		return nil, nil
+	}
+
+	if _, err := b.checkInLibraryKey(ctx, req.Storage, set, apiKeyId); err != nil {
+		return nil, err
+	}
+
+	if err := setLibrarySet(ctx, req.Storage, setName, set); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) libraryKeyRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	setName, apiKeyId, checkOutId, err := libraryCheckOutFromSecret(req)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := getLibrarySet(ctx, req.Storage, setName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving library set: %w", err)
+	}
+
+	if set == nil {
+		return nil, fmt.Errorf("library set %q no longer exists", setName)
+	}
+
+	key, ok := set.Keys[apiKeyId]
+	if !ok || key.CheckOut == nil || key.CheckOut.ID != checkOutId {
+		return nil, fmt.Errorf("API key %q is no longer checked out under this lease", apiKeyId)
+	}
+
+	ttl, warnings, err := framework.CalculateTTL(b.System(), req.Secret.Increment, set.TTL, 0, set.MaxTTL, 0, req.Secret.IssueTime)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{Secret: req.Secret}
+	resp.Secret.TTL = ttl
+	resp.Secret.MaxTTL = set.MaxTTL
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+
+	return resp, nil
+}
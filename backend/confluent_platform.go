@@ -0,0 +1,224 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-cleanhttp"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mdsAuthenticatePath = "/security/1.0/authenticate"
+
+	// mdsImpersonationPath issues a token on behalf of another principal. The
+	// configured user must be listed in the MDS
+	// confluent.metadata.server.impersonation.super.users setting.
+	mdsImpersonationPath = "/security/1.0/impersonation/token"
+
+	// mdsTokenRefreshWindow is how long before expiry the login token is
+	// refreshed.
+	mdsTokenRefreshWindow = 30 * time.Second
+)
+
+// mdsClient talks to the Metadata Service (MDS) of a self-managed Confluent
+// Platform deployment. It logs in with basic auth and uses the returned
+// token for every other call.
+type mdsClient struct {
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+
+	tokenLock   sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// mdsToken is returned by MDS when authenticating or impersonating.
+type mdsToken struct {
+	AuthToken string `json:"auth_token"`
+	TokenType string `json:"token_type"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// mdsScope identifies the clusters a role binding applies to.
+type mdsScope struct {
+	Clusters map[string]string `json:"clusters"`
+}
+
+// mdsResourcePattern restricts a role binding to matching resources.
+type mdsResourcePattern struct {
+	ResourceType string `json:"resourceType"`
+	Name         string `json:"name"`
+	PatternType  string `json:"patternType"`
+}
+
+func newMDSClient(config *clientConfig) (*mdsClient, error) {
+	if config.URL == "" {
+		return nil, errors.New("url of the Metadata Service must be provided for Confluent Platform")
+	}
+
+	if config.Username == "" || config.Password == "" {
+		return nil, errors.New("both username and password must be provided")
+	}
+
+	return &mdsClient{
+		baseURL:    strings.TrimSuffix(config.URL, "/"),
+		username:   config.Username,
+		password:   config.Password,
+		httpClient: cleanhttp.DefaultPooledClient(),
+	}, nil
+}
+
+func (c *mdsClient) connectionType() string {
+	return connectionTypePlatform
+}
+
+// authToken returns the client's login token, logging in again once the
+// cached token is close to expiry.
+func (c *mdsClient) authToken(ctx context.Context) (string, error) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+
+	if c.token != "" && time.Now().Before(c.tokenExpiry.Add(-mdsTokenRefreshWindow)) {
+		return c.token, nil
+	}
+
+	token := new(mdsToken)
+	start := time.Now()
+	err := c.send(ctx, http.MethodGet, mdsAuthenticatePath, nil, token, func(req *http.Request) {
+		req.SetBasicAuth(c.username, c.password)
+	})
+	measureAPICall(c.connectionType(), "authenticate", start, err)
+	if err != nil {
+		return "", fmt.Errorf("error logging in to the Metadata Service: %w", err)
+	}
+
+	c.token = token.AuthToken
+	c.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// do performs a request against MDS authenticated with the login token.
+func (c *mdsClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.send(ctx, method, path, body, out, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	})
+}
+
+func (c *mdsClient) send(ctx context.Context, method, path string, body, out interface{}, authenticate func(*http.Request)) error {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	authenticate(req)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling Metadata Service %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading Metadata Service response for %s: %w", path, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return &confluentAPIError{
+			StatusCode: resp.StatusCode,
+			Err:        errors.New(strings.TrimSpace(string(respBody))),
+			RequestID:  resp.Header.Get(requestIDHeader),
+		}
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func mdsRolePath(principal, roleName string) string {
+	return "/security/1.0/principals/" + neturl.PathEscape(principal) + "/roles/" + neturl.PathEscape(roleName)
+}
+
+// addMDSRoleBinding grants roleName to principal in the scope, limited to the
+// resource patterns if any are given.
+func addMDSRoleBinding(ctx context.Context, c *mdsClient, principal string, binding platformRoleBinding, scope mdsScope) error {
+	start := time.Now()
+	var err error
+	if binding.ResourcePattern == nil {
+		err = c.do(ctx, http.MethodPost, mdsRolePath(principal, binding.RoleName), scope, nil)
+	} else {
+		err = c.do(ctx, http.MethodPost, mdsRolePath(principal, binding.RoleName)+"/bindings", map[string]interface{}{
+			"scope":            scope,
+			"resourcePatterns": []mdsResourcePattern{*binding.ResourcePattern},
+		}, nil)
+	}
+	measureAPICall(c.connectionType(), "addRoleBinding", start, err)
+
+	if err != nil {
+		return fmt.Errorf("error granting %s to %s: %w", binding, principal, err)
+	}
+	return nil
+}
+
+// removeMDSRoleBinding revokes a binding granted by addMDSRoleBinding.
+func removeMDSRoleBinding(ctx context.Context, c *mdsClient, principal string, binding platformRoleBinding, scope mdsScope) error {
+	start := time.Now()
+	var err error
+	if binding.ResourcePattern == nil {
+		err = c.do(ctx, http.MethodDelete, mdsRolePath(principal, binding.RoleName), scope, nil)
+	} else {
+		err = c.do(ctx, http.MethodDelete, mdsRolePath(principal, binding.RoleName)+"/bindings", map[string]interface{}{
+			"scope":            scope,
+			"resourcePatterns": []mdsResourcePattern{*binding.ResourcePattern},
+		}, nil)
+	}
+	measureAPICall(c.connectionType(), "removeRoleBinding", start, err)
+
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("error revoking %s from %s: %w", binding, principal, err)
+	}
+	return nil
+}
+
+// impersonateMDSPrincipal returns an MDS-signed token for the principal.
+func impersonateMDSPrincipal(ctx context.Context, c *mdsClient, principal string) (*mdsToken, error) {
+	token := new(mdsToken)
+	start := time.Now()
+	err := c.do(ctx, http.MethodPost, mdsImpersonationPath, map[string]interface{}{
+		"principal": principal,
+	}, token)
+	measureAPICall(c.connectionType(), "impersonate", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("error issuing a Metadata Service token for %s: %w", principal, err)
+	}
+	return token, nil
+}
@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// resourceAPIPaths maps Confluent resource ID prefixes to the API collection
+// that can look them up by ID within an environment.
+var resourceAPIPaths = map[string]string{
+	"lkc-":    "/cmk/v2/clusters/",
+	"lsrc-":   "/srcm/v3/clusters/",
+	"lksqlc-": "/ksqldbcm/v2/clusters/",
+}
+
+// schemaRegistryCluster is the Schema Registry cluster of an environment.
+type schemaRegistryCluster struct {
+	ID   string `json:"id"`
+	Spec struct {
+		HttpEndpoint string `json:"http_endpoint"`
+	} `json:"spec"`
+}
+
+// getSchemaRegistryCluster looks up the Schema Registry cluster of an
+// environment through the SRCM API.
+func getSchemaRegistryCluster(ctx context.Context, c cloudClient, environment string) (*schemaRegistryCluster, error) {
+	var list struct {
+		Data []schemaRegistryCluster `json:"data"`
+	}
+
+	query := neturl.Values{"environment": []string{environment}}
+	if err := c.do(ctx, http.MethodGet, "/srcm/v3/clusters", query, nil, &list); err != nil {
+		return nil, fmt.Errorf("error listing Schema Registry clusters in %q: %w", environment, err)
+	}
+
+	if len(list.Data) == 0 {
+		return nil, fmt.Errorf("environment %q has no Schema Registry cluster", environment)
+	}
+	return &list.Data[0], nil
+}
+
+// ksqlDBCluster is a ksqlDB cluster and its REST endpoint.
+type ksqlDBCluster struct {
+	ID   string `json:"id"`
+	Spec struct {
+		HttpEndpoint string `json:"http_endpoint"`
+	} `json:"spec"`
+}
+
+func getKsqlDBCluster(ctx context.Context, c cloudClient, id, environment string) (*ksqlDBCluster, error) {
+	cluster := new(ksqlDBCluster)
+
+	query := neturl.Values{"environment": []string{environment}}
+	if err := c.do(ctx, http.MethodGet, "/ksqldbcm/v2/clusters/"+neturl.PathEscape(id), query, nil, cluster); err != nil {
+		return nil, fmt.Errorf("error reading ksqlDB cluster %q: %w", id, err)
+	}
+	return cluster, nil
+}
+
+// flinkComputePool is a Flink compute pool and the region it runs in.
+type flinkComputePool struct {
+	ID   string `json:"id"`
+	Spec struct {
+		Cloud  string `json:"cloud"`
+		Region string `json:"region"`
+	} `json:"spec"`
+}
+
+func getFlinkComputePool(ctx context.Context, c cloudClient, id, environment string) (*flinkComputePool, error) {
+	pool := new(flinkComputePool)
+
+	query := neturl.Values{"environment": []string{environment}}
+	if err := c.do(ctx, http.MethodGet, "/fcpm/v2/compute-pools/"+neturl.PathEscape(id), query, nil, pool); err != nil {
+		return nil, fmt.Errorf("error reading Flink compute pool %q: %w", id, err)
+	}
+	return pool, nil
+}
+
+// getOrganizationID returns the configured organization ID, or looks up the
+// organization the configured credentials belong to.
+func getOrganizationID(ctx context.Context, c cloudClient) (string, error) {
+	if id := c.configuredOrganizationID(); id != "" {
+		return id, nil
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+
+	if err := c.do(ctx, http.MethodGet, "/org/v2/organizations", nil, nil, &list); err != nil {
+		return "", fmt.Errorf("error listing Confluent organizations: %w", err)
+	}
+
+	if len(list.Data) != 1 {
+		return "", fmt.Errorf("expected exactly one organization, found %d; set organization_id in config", len(list.Data))
+	}
+	return list.Data[0].ID, nil
+}
+
+func getEnvironment(ctx context.Context, c cloudClient, id string) error {
+	if err := c.do(ctx, http.MethodGet, "/org/v2/environments/"+neturl.PathEscape(id), nil, nil, nil); err != nil {
+		return fmt.Errorf("error reading Confluent environment %q: %w", id, err)
+	}
+	return nil
+}
+
+// getResource checks that a resource exists in the given environment. Kinds
+// of resources that cannot be looked up are assumed to exist.
+func getResource(ctx context.Context, c cloudClient, id, environment string) error {
+	for prefix, path := range resourceAPIPaths {
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+
+		query := neturl.Values{"environment": []string{environment}}
+		if err := c.do(ctx, http.MethodGet, path+neturl.PathEscape(id), query, nil, nil); err != nil {
+			return fmt.Errorf("error reading Confluent resource %q: %w", id, err)
+		}
+		return nil
+	}
+	return nil
+}
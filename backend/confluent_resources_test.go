@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResourceLookups(t *testing.T) {
+	fake := newFakeCloud()
+	fake.addResource("/srcm/v3/clusters/lsrc-123", "env-123", map[string]interface{}{
+		"id":   "lsrc-123",
+		"spec": map[string]interface{}{"http_endpoint": "https://psrc-123.confluent.cloud"},
+	})
+	fake.addResource("/cmk/v2/clusters/lkc-123", "env-123", map[string]interface{}{"id": "lkc-123"})
+
+	t.Run("Schema Registry cluster", func(t *testing.T) {
+		cluster, err := getSchemaRegistryCluster(context.Background(), fake, "env-123")
+		require.NoError(t, err)
+		require.Equal(t, "lsrc-123", cluster.ID)
+		require.Equal(t, "https://psrc-123.confluent.cloud", cluster.Spec.HttpEndpoint)
+
+		_, err = getSchemaRegistryCluster(context.Background(), fake, "env-456")
+		require.ErrorContains(t, err, "has no Schema Registry cluster")
+	})
+
+	t.Run("Resource in environment", func(t *testing.T) {
+		require.NoError(t, getResource(context.Background(), fake, "lkc-123", "env-123"))
+
+		err := getResource(context.Background(), fake, "lkc-123", "env-456")
+		require.True(t, isNotFound(err))
+	})
+
+	t.Run("Organization lookup", func(t *testing.T) {
+		fake.organizationID = ""
+		_, err := getOrganizationID(context.Background(), fake)
+		require.ErrorContains(t, err, "found 0")
+	})
+}
@@ -0,0 +1,92 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+)
+
+const (
+	roleBindingsPath = "/iam/v2/role-bindings"
+)
+
+// roleBinding grants a principal a Confluent RBAC role on resources matching
+// a CRN pattern.
+type roleBinding struct {
+	ID         string `json:"id,omitempty"`
+	Principal  string `json:"principal"`
+	RoleName   string `json:"role_name"`
+	CrnPattern string `json:"crn_pattern"`
+}
+
+type roleBindingList struct {
+	Metadata struct {
+		Next string `json:"next"`
+	} `json:"metadata"`
+	Data []roleBinding `json:"data"`
+}
+
+// servicePrincipal returns the RBAC principal for a service account or user.
+func servicePrincipal(id string) string {
+	return "User:" + id
+}
+
+func (c *client) listRoleBindings(ctx context.Context, principal, roleName, crnPattern string) ([]roleBinding, error) {
+	query := neturl.Values{}
+	query.Set("crn_pattern", crnPattern)
+	query.Set("page_size", fmt.Sprint(listPageSize))
+	if principal != "" {
+		query.Set("principal", principal)
+	}
+	if roleName != "" {
+		query.Set("role_name", roleName)
+	}
+
+	var bindings []roleBinding
+	for {
+		var page roleBindingList
+		if err := c.do(ctx, http.MethodGet, roleBindingsPath, query, nil, &page); err != nil {
+			return nil, fmt.Errorf("error listing Confluent role bindings: %w", err)
+		}
+		bindings = append(bindings, page.Data...)
+
+		pageToken := nextPageToken(page.Metadata.Next)
+		if pageToken == "" {
+			return bindings, nil
+		}
+		query.Set("page_token", pageToken)
+	}
+}
+
+func (c *client) createRoleBinding(ctx context.Context, binding roleBinding) (*roleBinding, error) {
+	created := new(roleBinding)
+	if err := c.do(ctx, http.MethodPost, roleBindingsPath, nil, binding, created); err != nil {
+		return nil, fmt.Errorf("error creating Confluent role binding %s for %s: %w", binding.RoleName, binding.Principal, err)
+	}
+	return created, nil
+}
+
+func (c *client) deleteRoleBinding(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, roleBindingsPath+"/"+neturl.PathEscape(id), nil, nil, nil); err != nil {
+		return fmt.Errorf("error deleting Confluent role binding %q: %w", id, err)
+	}
+	return nil
+}
+
+// ensureRoleBinding creates the binding unless an identical one exists.
+func ensureRoleBinding(ctx context.Context, c cloudClient, binding roleBinding) error {
+	existing, err := c.listRoleBindings(ctx, binding.Principal, binding.RoleName, binding.CrnPattern)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range existing {
+		if e.CrnPattern == binding.CrnPattern {
+			return nil
+		}
+	}
+
+	_, err = c.createRoleBinding(ctx, binding)
+	return err
+}
@@ -0,0 +1,216 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	iamv2 "github.com/confluentinc/ccloud-sdk-go-v2/iam/v2"
+	neturl "net/url"
+	"strings"
+)
+
+const (
+	// listPageSize is the page size requested from paginated Confluent APIs.
+	listPageSize = 100
+)
+
+// nextPageToken extracts the page_token query parameter from the "next" URL
+// returned in list metadata. It returns an empty string on the last page.
+func nextPageToken(next string) string {
+	if next == "" {
+		return ""
+	}
+
+	u, err := neturl.Parse(next)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("page_token")
+}
+
+func (c *client) listServiceAccounts(ctx context.Context) ([]iamv2.IamV2ServiceAccount, error) {
+	auth := c.authContext()
+
+	var accounts []iamv2.IamV2ServiceAccount
+	pageToken := ""
+	for {
+		req := c.iam.ServiceAccountsIamV2Api.ListIamV2ServiceAccounts(auth).PageSize(listPageSize)
+		if pageToken != "" {
+			req = req.PageToken(pageToken)
+		}
+
+		page, httpResp, err := req.Execute()
+		if err != nil {
+			return nil, fmt.Errorf("error listing Confluent service accounts: %w", wrapAPIError(err, httpResp))
+		}
+		accounts = append(accounts, page.GetData()...)
+
+		meta := page.GetMetadata()
+		if pageToken = nextPageToken(meta.GetNext()); pageToken == "" {
+			return accounts, nil
+		}
+	}
+}
+
+func (c *client) getServiceAccount(ctx context.Context, id string) (*iamv2.IamV2ServiceAccount, error) {
+	auth := c.authContext()
+
+	account, httpResp, err := c.iam.ServiceAccountsIamV2Api.GetIamV2ServiceAccount(auth, id).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error reading Confluent service account %q: %w", id, wrapAPIError(err, httpResp))
+	}
+	return &account, nil
+}
+
+func (c *client) createServiceAccount(ctx context.Context, displayName, description string) (*iamv2.IamV2ServiceAccount, error) {
+	auth := c.authContext()
+
+	request := iamv2.IamV2ServiceAccount{
+		DisplayName: &displayName,
+		Description: &description,
+	}
+
+	account, httpResp, err := c.iam.ServiceAccountsIamV2Api.
+		CreateIamV2ServiceAccount(auth).
+		IamV2ServiceAccount(request).
+		Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error creating Confluent service account %q: %w", displayName, wrapAPIError(err, httpResp))
+	}
+	return &account, nil
+}
+
+func (c *client) updateServiceAccount(ctx context.Context, id, description string) error {
+	auth := c.authContext()
+
+	update := iamv2.NewIamV2ServiceAccountUpdate()
+	update.SetDescription(description)
+
+	_, httpResp, err := c.iam.ServiceAccountsIamV2Api.
+		UpdateIamV2ServiceAccount(auth, id).
+		IamV2ServiceAccountUpdate(*update).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("error updating Confluent service account %q: %w", id, wrapAPIError(err, httpResp))
+	}
+	return nil
+}
+
+func (c *client) deleteServiceAccount(ctx context.Context, id string) error {
+	auth := c.authContext()
+
+	httpResp, err := c.iam.ServiceAccountsIamV2Api.DeleteIamV2ServiceAccount(auth, id).Execute()
+	if err != nil {
+		return fmt.Errorf("error deleting Confluent service account %q: %w", id, wrapAPIError(err, httpResp))
+	}
+	return nil
+}
+
+// findServiceAccountByName resolves a service account display name to its ID.
+func findServiceAccountByName(ctx context.Context, c cloudClient, displayName string) (string, error) {
+	accounts, err := c.listServiceAccounts(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	id := ""
+	for _, account := range accounts {
+		if account.GetDisplayName() != displayName {
+			continue
+		}
+		if id != "" {
+			return "", fmt.Errorf("more than one service account is named %q", displayName)
+		}
+		id = account.GetId()
+	}
+
+	if id == "" {
+		return "", fmt.Errorf("no service account is named %q", displayName)
+	}
+	return id, nil
+}
+
+func (c *client) getUser(ctx context.Context, id string) (*iamv2.IamV2User, error) {
+	auth := c.authContext()
+
+	user, httpResp, err := c.iam.UsersIamV2Api.GetIamV2User(auth, id).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error reading Confluent user %q: %w", id, wrapAPIError(err, httpResp))
+	}
+	return &user, nil
+}
+
+func (c *client) listUsers(ctx context.Context) ([]iamv2.IamV2User, error) {
+	auth := c.authContext()
+
+	var users []iamv2.IamV2User
+	pageToken := ""
+	for {
+		req := c.iam.UsersIamV2Api.ListIamV2Users(auth).PageSize(listPageSize)
+		if pageToken != "" {
+			req = req.PageToken(pageToken)
+		}
+
+		page, httpResp, err := req.Execute()
+		if err != nil {
+			return nil, fmt.Errorf("error listing Confluent users: %w", wrapAPIError(err, httpResp))
+		}
+		users = append(users, page.GetData()...)
+
+		meta := page.GetMetadata()
+		if pageToken = nextPageToken(meta.GetNext()); pageToken == "" {
+			return users, nil
+		}
+	}
+}
+
+// findUserByEmail resolves a user account's email address to its ID.
+func findUserByEmail(ctx context.Context, c cloudClient, email string) (string, error) {
+	users, err := c.listUsers(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.GetEmail(), email) {
+			return user.GetId(), nil
+		}
+	}
+	return "", fmt.Errorf("no user has the email address %q", email)
+}
+
+// listTokens returns the IDs of the API keys owned by a service account or
+// user.
+func (c *client) listTokens(ctx context.Context, owner string) ([]string, error) {
+	auth := c.authContext()
+
+	var ids []string
+	pageToken := ""
+	for {
+		req := c.apikeys.APIKeysIamV2Api.ListIamV2ApiKeys(auth).SpecOwner(owner).PageSize(listPageSize)
+		if pageToken != "" {
+			req = req.PageToken(pageToken)
+		}
+
+		page, httpResp, err := req.Execute()
+		if err != nil {
+			return nil, fmt.Errorf("error listing Confluent API Keys for %q: %w", owner, wrapAPIError(err, httpResp))
+		}
+		for _, key := range page.GetData() {
+			ids = append(ids, key.GetId())
+		}
+
+		meta := page.GetMetadata()
+		if pageToken = nextPageToken(meta.GetNext()); pageToken == "" {
+			return ids, nil
+		}
+	}
+}
+
+// countTokens returns the number of API keys owned by a service account.
+func countTokens(ctx context.Context, c cloudClient, owner string) (int, error) {
+	ids, err := c.listTokens(ctx, owner)
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
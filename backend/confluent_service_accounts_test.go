@@ -0,0 +1,12 @@
+package backend
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNextPageToken(t *testing.T) {
+	require.Equal(t, "", nextPageToken(""))
+	require.Equal(t, "", nextPageToken("https://api.confluent.cloud/iam/v2/service-accounts?page_size=100"))
+	require.Equal(t, "abc", nextPageToken("https://api.confluent.cloud/iam/v2/service-accounts?page_size=100&page_token=abc"))
+}
@@ -0,0 +1,220 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robwittman/vault-plugin-secrets-confluent/internal/ccloudfake"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestEndToEnd runs the plugin against the ccloudfake server, so requests go
+// through the real SDK serialization and pagination. The engine has no tidy
+// endpoint: keys replaced by rotations are cleaned up by the periodic
+// function, which is covered instead.
+func TestEndToEnd(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	srv := ccloudfake.NewServer(username, password)
+	defer srv.Close()
+	srv.MaxPageSize = 1
+
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"username": username,
+		"password": password,
+		"url":      srv.URL,
+	}))
+
+	srv.AddServiceAccount("billing", "")
+	serviceAccountID := srv.AddServiceAccount("orders", "")
+	srv.AddServiceAccount("payments", "")
+
+	t.Run("Create Role by service account name", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"service_account_name": "orders",
+			"ttl":                  testTTL,
+			"max_ttl":              testMaxTTL,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		role, err := b.getRole(context.Background(), s, "orders")
+		require.NoError(t, err)
+		require.Equal(t, serviceAccountID, role.ServiceAccount)
+	})
+
+	t.Run("Issue, Renew and Revoke", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		apiKey := resp.Data["api_key"].(string)
+		require.True(t, srv.HasAPIKey(apiKey))
+
+		resp.Secret.IssueTime = time.Now()
+		renewed, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, time.Duration(testTTL)*time.Second, renewed.Secret.TTL)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, srv.HasAPIKey(apiKey))
+	})
+
+	t.Run("Rotate Lease and delete replaced Key", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/orders",
+			EntityID:  "entity-a",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		original := resp.Data["api_key"].(string)
+		testLeaseRenew(t, b, s, resp.Secret, "confluent/creds/orders/e2e")
+
+		rotated, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "creds/orders/rotate",
+			MountPoint: "confluent/",
+			EntityID:   "entity-a",
+			Data: map[string]interface{}{
+				"lease_id": "confluent/creds/orders/e2e",
+				"api_key":  original,
+				"overlap":  60,
+			},
+			Storage: s,
+		})
+		require.NoError(t, err)
+		require.False(t, rotated.IsError())
+		current := rotated.Data["api_key"].(string)
+		require.True(t, srv.HasAPIKey(current))
+
+		// The replaced key is kept for the overlap window.
+		require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+		require.True(t, srv.HasAPIKey(original))
+
+		require.NoError(t, setPendingDeletion(context.Background(), s, &pendingDeletion{
+			APIKey:      original,
+			Role:        "orders",
+			DeleteAfter: time.Now().Add(-time.Second),
+		}))
+		require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+		require.False(t, srv.HasAPIKey(original))
+		require.True(t, srv.HasAPIKey(current))
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, srv.HasAPIKey(current))
+
+		leased, err := getLeaseKey(context.Background(), s, original)
+		require.NoError(t, err)
+		require.Nil(t, leased)
+	})
+
+	t.Run("Remove expired pending deletions", func(t *testing.T) {
+		// Keys deleted in Confluent already are treated as deleted.
+		deleted, _ := srv.AddAPIKey(serviceAccountID, "")
+		c, err := b.getClient(context.Background(), s)
+		require.NoError(t, err)
+		require.NoError(t, c.deleteToken(context.Background(), deleted))
+
+		expired, _ := srv.AddAPIKey(serviceAccountID, "")
+		for _, id := range []string{deleted, expired} {
+			require.NoError(t, setPendingDeletion(context.Background(), s, &pendingDeletion{
+				APIKey:      id,
+				Role:        "orders",
+				DeleteAfter: time.Now().Add(-time.Second),
+			}))
+		}
+
+		require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+		require.False(t, srv.HasAPIKey(expired))
+
+		ids, err := s.List(context.Background(), pendingDeletionStoragePrefix)
+		require.NoError(t, err)
+		require.Empty(t, ids)
+	})
+
+	t.Run("Import with wrong secret - fail", func(t *testing.T) {
+		apiKey, _ := srv.AddAPIKey(serviceAccountID, "")
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "import/orders",
+			Data:      map[string]interface{}{"api_key": apiKey, "api_secret": "wrong", "static": true},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Rotate library key on check-in", func(t *testing.T) {
+		first, firstSecret := srv.AddAPIKey(serviceAccountID, "")
+		second, secondSecret := srv.AddAPIKey(serviceAccountID, "")
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "library/" + librarySetName,
+			Data: map[string]interface{}{
+				"api_keys":           map[string]interface{}{first: firstSecret, second: secondSecret},
+				"rotate_on_check_in": true,
+			},
+			Storage: s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = testLibraryCheckOut(t, b, s, "entity-a")
+		require.NoError(t, err)
+		checkedOut := resp.Data["api_key"].(string)
+
+		_, err = testLibraryCheckIn(t, b, s, "entity-a", nil)
+		require.NoError(t, err)
+		require.False(t, srv.HasAPIKey(checkedOut))
+
+		set, err := getLibrarySet(context.Background(), s, librarySetName)
+		require.NoError(t, err)
+		require.Len(t, set.Keys, 2)
+		for id := range set.Keys {
+			require.True(t, srv.HasAPIKey(id))
+		}
+	})
+
+	t.Run("Delete managed service account with keys - fail", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "service-account/reports",
+			Data:      map[string]interface{}{"display_name": "reports"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		accountID := resp.Data["id"].(string)
+		srv.AddAPIKey(accountID, "")
+		srv.AddAPIKey(accountID, "")
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "service-account/reports",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "2 API keys")
+	})
+}
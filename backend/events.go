@@ -0,0 +1,36 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	eventCredsCreate = "confluent/creds-create"
+	eventCredsRevoke = "confluent/creds-revoke"
+	eventCredsRotate = "confluent/creds-rotate"
+	eventConfigWrite = "confluent/config-write"
+	eventRoleWrite   = "confluent/role-write"
+	eventRoleDelete  = "confluent/role-delete"
+
+	eventApprovalRequest = "confluent/approval-request"
+	eventApprovalApprove = "confluent/approval-approve"
+)
+
+// sendEvent publishes a plugin event describing a change made by the
+// request. Metadata must never contain secrets. Failing to send an event does
+// not fail the request, and mounts without events enabled are ignored.
+func (b *Backend) sendEvent(ctx context.Context, req *logical.Request, eventType string, metadataPairs ...string) {
+	metadataPairs = append(metadataPairs,
+		"modified", "true",
+		"operation", string(req.Operation),
+		"path", req.Path,
+	)
+
+	err := logical.SendEvent(ctx, b, eventType, metadataPairs...)
+	if err != nil && !errors.Is(err, framework.ErrNoEvents) {
+		b.logWarn("error sending event", "event_type", eventType, "error", err)
+	}
+}
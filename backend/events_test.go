@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+type mockEventSender struct {
+	lock   sync.Mutex
+	events []*logical.EventReceived
+}
+
+func (m *mockEventSender) SendEvent(ctx context.Context, eventType logical.EventType, event *logical.EventData) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.events = append(m.events, &logical.EventReceived{EventType: string(eventType), Event: event})
+	return nil
+}
+
+// metadata returns the metadata of the events sent with the given type.
+func (m *mockEventSender) metadata(eventType string) []map[string]interface{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var metadata []map[string]interface{}
+	for _, e := range m.events {
+		if e.EventType == eventType {
+			metadata = append(metadata, e.Event.Metadata.AsMap())
+		}
+	}
+	return metadata
+}
+
+func TestEvents(t *testing.T) {
+	events := &mockEventSender{}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = new(logical.InmemStorage)
+	config.Logger = hclog.NewNullLogger()
+	config.System = logical.TestSystemView()
+	config.EventsSender = events
+
+	lb, err := Factory(context.Background(), config)
+	require.NoError(t, err)
+	b, s := lb.(*Backend), config.StorageView
+
+	fake := useFakeCloud(b)
+	fake.addServiceAccount("sa-123", "orders")
+
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"username": username,
+		"password": password,
+	}))
+	require.Len(t, events.metadata(eventConfigWrite), 1)
+	require.NotContains(t, events.metadata(eventConfigWrite)[0], "password")
+
+	_, err = testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+		"skip_validation": true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "orders", events.metadata(eventRoleWrite)[0]["role"])
+	require.Equal(t, "sa-123", events.metadata(eventRoleWrite)[0]["owner"])
+
+	resp, err := testCredentialsRead(b, s, "orders")
+	require.NoError(t, err)
+
+	created := events.metadata(eventCredsCreate)
+	require.Len(t, created, 1)
+	require.Equal(t, resp.Data["api_key"], created[0]["api_key"])
+	require.Equal(t, "sa-123", created[0]["owner"])
+	for _, v := range created[0] {
+		require.NotEqual(t, resp.Data["api_secret"], v)
+	}
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resp.Data["api_key"], events.metadata(eventCredsRevoke)[0]["api_key"])
+	require.Equal(t, "orders", events.metadata(eventCredsRevoke)[0]["role"])
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "role/orders",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "orders", events.metadata(eventRoleDelete)[0]["role"])
+}
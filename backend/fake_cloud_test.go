@@ -0,0 +1,546 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	iamv2 "github.com/confluentinc/ccloud-sdk-go-v2/iam/v2"
+	"github.com/hashicorp/vault/sdk/logical"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeCloud is an in-memory cloudClient for tests. Besides storing API keys,
+// service accounts, role bindings and the REST resources served by do, it
+// can inject latency, queued error responses and eventual consistency into
+// every call.
+type fakeCloud struct {
+	mu sync.Mutex
+
+	// latency is added to every call, unless the context is done first.
+	latency time.Duration
+
+	// visibilityDelay hides newly created API keys and service accounts
+	// from reads and lists for a while, like Confluent's eventually
+	// consistent APIs.
+	visibilityDelay time.Duration
+
+	organizationID string
+
+	faults map[string][]int
+	calls  map[string]int
+	nextID int
+
+	apiKeys         map[string]*fakeAPIKey
+	serviceAccounts map[string]*fakeServiceAccount
+	users           []iamv2.IamV2User
+	roleBindings    map[string]roleBinding
+	resources       map[string]fakeResource
+}
+
+// fakeResource is an object served by do, such as a cluster or an identity
+// pool, stored under its REST path.
+type fakeResource struct {
+	environment string
+	object      map[string]interface{}
+}
+
+type fakeAPIKey struct {
+	secret    string
+	spec      apiKeySpec
+	createdAt time.Time
+}
+
+type fakeServiceAccount struct {
+	account   iamv2.IamV2ServiceAccount
+	createdAt time.Time
+}
+
+var _ cloudClient = (*fakeCloud)(nil)
+
+func newFakeCloud() *fakeCloud {
+	return &fakeCloud{
+		organizationID:  "org-fake",
+		faults:          map[string][]int{},
+		calls:           map[string]int{},
+		apiKeys:         map[string]*fakeAPIKey{},
+		serviceAccounts: map[string]*fakeServiceAccount{},
+		roleBindings:    map[string]roleBinding{},
+		resources:       map[string]fakeResource{},
+	}
+}
+
+// useFakeCloud makes the backend use a new fakeCloud instead of Confluent.
+func useFakeCloud(b *Backend) *fakeCloud {
+	f := newFakeCloud()
+	b.getClientHook = func(ctx context.Context, s logical.Storage) (cloudClient, error) {
+		return f, nil
+	}
+	return f
+}
+
+// failNext makes the next calls of the operation, named after the
+// cloudClient method, fail with the given status codes in order.
+func (f *fakeCloud) failNext(op string, statusCodes ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults[op] = append(f.faults[op], statusCodes...)
+}
+
+// callCount returns how often the operation has been called.
+func (f *fakeCloud) callCount(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[op]
+}
+
+// addServiceAccount seeds a service account that is immediately visible.
+func (f *fakeCloud) addServiceAccount(id, displayName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.serviceAccounts[id] = &fakeServiceAccount{
+		account: iamv2.IamV2ServiceAccount{Id: &id, DisplayName: &displayName},
+	}
+}
+
+// addUser seeds a user account.
+func (f *fakeCloud) addUser(id, email string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users = append(f.users, iamv2.IamV2User{Id: &id, Email: &email})
+}
+
+// addResource seeds an object that do serves at path. Reads scoped to an
+// environment only find it in that environment.
+func (f *fakeCloud) addResource(path, environment string, object map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resources[path] = fakeResource{environment: environment, object: object}
+}
+
+// resource returns the object stored at path by do or addResource, or nil.
+func (f *fakeCloud) resource(path string) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.resources[path].object
+}
+
+// hasToken reports whether the API key exists, regardless of visibility.
+func (f *fakeCloud) hasToken(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.apiKeys[id]
+	return ok
+}
+
+// call records the operation, applies latency and returns any queued fault.
+func (f *fakeCloud) call(ctx context.Context, op string) error {
+	f.mu.Lock()
+	f.calls[op]++
+	latency := f.latency
+	var fault int
+	if queued := f.faults[op]; len(queued) > 0 {
+		fault, f.faults[op] = queued[0], queued[1:]
+	}
+	f.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if fault != 0 {
+		return &confluentAPIError{StatusCode: fault, Err: fmt.Errorf("injected %s failure", http.StatusText(fault))}
+	}
+	return nil
+}
+
+func (f *fakeCloud) visible(createdAt time.Time) bool {
+	return time.Since(createdAt) >= f.visibilityDelay
+}
+
+func (f *fakeCloud) newID(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s%d", prefix, f.nextID)
+}
+
+func notFound(kind, id string) error {
+	return &confluentAPIError{StatusCode: http.StatusNotFound, Err: fmt.Errorf("%s %q not found", kind, id)}
+}
+
+func (f *fakeCloud) connectionType() string {
+	return connectionTypeCloud
+}
+
+func (f *fakeCloud) configuredOrganizationID() string {
+	return f.organizationID
+}
+
+func (f *fakeCloud) createToken(ctx context.Context, keySpec apiKeySpec) (*confluentApiKey, error) {
+	if err := f.call(ctx, "createToken"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.newID("KEY")
+	secret := "secret-" + id
+	f.apiKeys[id] = &fakeAPIKey{secret: secret, spec: keySpec, createdAt: time.Now()}
+	return &confluentApiKey{ApiKey: id, ApiSecret: secret}, nil
+}
+
+func (f *fakeCloud) getTokenSpec(ctx context.Context, apiKeyId string) (*apiKeySpec, error) {
+	if err := f.call(ctx, "getTokenSpec"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key, ok := f.apiKeys[apiKeyId]
+	if !ok || !f.visible(key.createdAt) {
+		return nil, notFound("API key", apiKeyId)
+	}
+	spec := key.spec
+	return &spec, nil
+}
+
+func (f *fakeCloud) listTokens(ctx context.Context, owner string) ([]string, error) {
+	if err := f.call(ctx, "listTokens"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ids []string
+	for id, key := range f.apiKeys {
+		if key.spec.Owner == owner && f.visible(key.createdAt) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (f *fakeCloud) verifyTokenSecret(ctx context.Context, apiKeyId, apiSecret string) error {
+	if err := f.call(ctx, "verifyTokenSecret"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if key, ok := f.apiKeys[apiKeyId]; !ok || key.secret != apiSecret {
+		return &confluentAPIError{StatusCode: http.StatusUnauthorized, Err: fmt.Errorf("API secret for key %q was rejected by Confluent", apiKeyId)}
+	}
+	return nil
+}
+
+func (f *fakeCloud) deleteToken(ctx context.Context, apiKeyId string) error {
+	if err := f.call(ctx, "deleteToken"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.apiKeys[apiKeyId]; !ok {
+		return notFound("API key", apiKeyId)
+	}
+	delete(f.apiKeys, apiKeyId)
+	return nil
+}
+
+func (f *fakeCloud) listServiceAccounts(ctx context.Context) ([]iamv2.IamV2ServiceAccount, error) {
+	if err := f.call(ctx, "listServiceAccounts"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var accounts []iamv2.IamV2ServiceAccount
+	for _, sa := range f.serviceAccounts {
+		if f.visible(sa.createdAt) {
+			accounts = append(accounts, sa.account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].GetId() < accounts[j].GetId() })
+	return accounts, nil
+}
+
+func (f *fakeCloud) getServiceAccount(ctx context.Context, id string) (*iamv2.IamV2ServiceAccount, error) {
+	if err := f.call(ctx, "getServiceAccount"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sa, ok := f.serviceAccounts[id]
+	if !ok || !f.visible(sa.createdAt) {
+		return nil, notFound("service account", id)
+	}
+	account := sa.account
+	return &account, nil
+}
+
+func (f *fakeCloud) createServiceAccount(ctx context.Context, displayName, description string) (*iamv2.IamV2ServiceAccount, error) {
+	if err := f.call(ctx, "createServiceAccount"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, sa := range f.serviceAccounts {
+		if sa.account.GetDisplayName() == displayName {
+			return nil, &confluentAPIError{StatusCode: http.StatusConflict, Err: errors.New("display name already in use")}
+		}
+	}
+
+	id := f.newID("sa-")
+	account := iamv2.IamV2ServiceAccount{Id: &id, DisplayName: &displayName, Description: &description}
+	f.serviceAccounts[id] = &fakeServiceAccount{account: account, createdAt: time.Now()}
+	return &account, nil
+}
+
+func (f *fakeCloud) updateServiceAccount(ctx context.Context, id, description string) error {
+	if err := f.call(ctx, "updateServiceAccount"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sa, ok := f.serviceAccounts[id]
+	if !ok {
+		return notFound("service account", id)
+	}
+	sa.account.Description = &description
+	return nil
+}
+
+func (f *fakeCloud) deleteServiceAccount(ctx context.Context, id string) error {
+	if err := f.call(ctx, "deleteServiceAccount"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.serviceAccounts[id]; !ok {
+		return notFound("service account", id)
+	}
+	delete(f.serviceAccounts, id)
+	return nil
+}
+
+func (f *fakeCloud) listUsers(ctx context.Context) ([]iamv2.IamV2User, error) {
+	if err := f.call(ctx, "listUsers"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]iamv2.IamV2User(nil), f.users...), nil
+}
+
+func (f *fakeCloud) getUser(ctx context.Context, id string) (*iamv2.IamV2User, error) {
+	if err := f.call(ctx, "getUser"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, user := range f.users {
+		if user.GetId() == id {
+			return &user, nil
+		}
+	}
+	return nil, notFound("user", id)
+}
+
+func (f *fakeCloud) listRoleBindings(ctx context.Context, principal, roleName, crnPattern string) ([]roleBinding, error) {
+	if err := f.call(ctx, "listRoleBindings"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var bindings []roleBinding
+	for _, binding := range f.roleBindings {
+		if (principal == "" || binding.Principal == principal) &&
+			(roleName == "" || binding.RoleName == roleName) &&
+			binding.CrnPattern == crnPattern {
+			bindings = append(bindings, binding)
+		}
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].ID < bindings[j].ID })
+	return bindings, nil
+}
+
+func (f *fakeCloud) createRoleBinding(ctx context.Context, binding roleBinding) (*roleBinding, error) {
+	if err := f.call(ctx, "createRoleBinding"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	binding.ID = f.newID("rb-")
+	f.roleBindings[binding.ID] = binding
+	return &binding, nil
+}
+
+func (f *fakeCloud) deleteRoleBinding(ctx context.Context, id string) error {
+	if err := f.call(ctx, "deleteRoleBinding"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.roleBindings[id]; !ok {
+		return notFound("role binding", id)
+	}
+	delete(f.roleBindings, id)
+	return nil
+}
+
+// do serves GET, POST, PATCH and DELETE on the REST resources stored in the
+// fake. Collections list the resources directly below them, and POST assigns
+// an ID. The organization list only holds organizationID, if set.
+func (f *fakeCloud) do(ctx context.Context, method, path string, query neturl.Values, body, out interface{}) error {
+	if err := f.call(ctx, "do"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	environment := query.Get("environment")
+	switch method {
+	case http.MethodGet:
+		if path == "/org/v2/organizations" {
+			orgs := []interface{}{}
+			if f.organizationID != "" {
+				orgs = append(orgs, map[string]interface{}{"id": f.organizationID})
+			}
+			return fakeDecode(map[string]interface{}{"data": orgs}, out)
+		}
+
+		if res, ok := f.resources[path]; ok {
+			if environment != "" && res.environment != environment {
+				return notFound("resource", path)
+			}
+			return fakeDecode(res.object, out)
+		}
+
+		if !f.isCollection(path) {
+			return notFound("resource", path)
+		}
+		list := []interface{}{}
+		for _, p := range f.children(path) {
+			if res := f.resources[p]; environment == "" || res.environment == environment {
+				list = append(list, res.object)
+			}
+		}
+		return fakeDecode(map[string]interface{}{"data": list}, out)
+
+	case http.MethodPost:
+		// Nested collections, such as identity pools, need their parent.
+		parent := path[:strings.LastIndex(path, "/")]
+		if _, ok := f.resources[parent]; !ok && strings.Count(parent, "/") >= 4 {
+			return notFound("resource", parent)
+		}
+
+		object, err := fakeObject(body)
+		if err != nil {
+			return err
+		}
+		kind := strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], "s")
+		object["id"] = f.newID(kind + "-")
+		f.resources[path+"/"+object["id"].(string)] = fakeResource{environment: environment, object: object}
+		return fakeDecode(object, out)
+
+	case http.MethodPatch:
+		res, ok := f.resources[path]
+		if !ok {
+			return notFound("resource", path)
+		}
+
+		update, err := fakeObject(body)
+		if err != nil {
+			return err
+		}
+		for k, v := range update {
+			res.object[k] = v
+		}
+		return fakeDecode(res.object, out)
+
+	case http.MethodDelete:
+		if _, ok := f.resources[path]; !ok {
+			return notFound("resource", path)
+		}
+		for p := range f.resources {
+			if p == path || strings.HasPrefix(p, path+"/") {
+				delete(f.resources, p)
+			}
+		}
+		return nil
+	}
+
+	return &confluentAPIError{StatusCode: http.StatusMethodNotAllowed, Err: fmt.Errorf("%s %s is not supported by the fake", method, path)}
+}
+
+// isCollection reports whether path lists resources: either resources are
+// stored below it, or it is a collection resources are looked up in.
+func (f *fakeCloud) isCollection(path string) bool {
+	for _, collection := range resourceAPIPaths {
+		if path+"/" == collection {
+			return true
+		}
+	}
+	return len(f.children(path)) > 0
+}
+
+// children returns the sorted paths of the resources directly below path.
+func (f *fakeCloud) children(path string) []string {
+	var paths []string
+	for p := range f.resources {
+		if rest, ok := strings.CutPrefix(p, path+"/"); ok && !strings.Contains(rest, "/") {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// fakeObject converts a request body to the generic form resources are
+// stored in.
+func fakeObject(body interface{}) (map[string]interface{}, error) {
+	object := map[string]interface{}{}
+	if body == nil {
+		return object, nil
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// fakeDecode copies a stored object into out, like decoding a response body.
+func fakeDecode(object interface{}, out interface{}) error {
+	if out == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/helper/identitytpl"
+	"github.com/hashicorp/vault/sdk/logical"
+	"strings"
+)
+
+// isIdentityTemplate reports whether s contains a Vault identity template,
+// such as {{identity.entity.metadata.confluent_sa}}.
+func isIdentityTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// validateIdentityTemplate checks the syntax of an identity template without
+// resolving it.
+func validateIdentityTemplate(s string) error {
+	_, _, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
+		String:            s,
+		ValidityCheckOnly: true,
+		Mode:              identitytpl.ACLTemplating,
+	})
+	return err
+}
+
+// populateIdentityTemplate resolves an identity template with the entity of
+// the request and the groups it belongs to.
+func (b *Backend) populateIdentityTemplate(req *logical.Request, tmpl string) (string, error) {
+	if req.EntityID == "" {
+		return "", errors.New("the request has no entity to resolve identity templates with")
+	}
+
+	entity, err := b.System().EntityInfo(req.EntityID)
+	if err != nil {
+		return "", fmt.Errorf("error looking up entity %q: %w", req.EntityID, err)
+	}
+	if entity == nil {
+		return "", fmt.Errorf("entity %q does not exist", req.EntityID)
+	}
+
+	groups, err := b.System().GroupsForEntity(req.EntityID)
+	if err != nil {
+		return "", fmt.Errorf("error looking up groups of entity %q: %w", req.EntityID, err)
+	}
+
+	_, value, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
+		String:      tmpl,
+		Entity:      entity,
+		Groups:      groups,
+		NamespaceID: entity.NamespaceID,
+		Mode:        identitytpl.ACLTemplating,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error resolving identity template %q: %w", tmpl, err)
+	}
+	if value == "" {
+		return "", fmt.Errorf("identity template %q resolved to an empty value", tmpl)
+	}
+	return value, nil
+}
+
+// allowsServiceAccount reports whether API keys owned by the service account
+// belong to the role. Roles with a templated service account allow any
+// account matching allowed_service_accounts.
+func (r *confluentRoleEntry) allowsServiceAccount(id string) bool {
+	if isIdentityTemplate(r.ServiceAccount) {
+		return strutil.StrListContainsGlob(r.AllowedServiceAccounts, id)
+	}
+	return r.ServiceAccount == id
+}
+
+// withIdentity returns a copy of the role with its identity templates
+// resolved for the request.
+func (b *Backend) withIdentity(req *logical.Request, r *confluentRoleEntry) (*confluentRoleEntry, error) {
+	resolved := *r
+
+	if isIdentityTemplate(r.ServiceAccount) {
+		serviceAccount, err := b.populateIdentityTemplate(req, r.ServiceAccount)
+		if err != nil {
+			return nil, err
+		}
+		if !r.allowsServiceAccount(serviceAccount) {
+			return nil, fmt.Errorf("service account %q is not allowed by the role", serviceAccount)
+		}
+		resolved.ServiceAccount = serviceAccount
+	}
+
+	if isIdentityTemplate(r.KeyDisplayName) {
+		displayName, err := b.populateIdentityTemplate(req, r.KeyDisplayName)
+		if err != nil {
+			return nil, err
+		}
+		resolved.KeyDisplayName = displayName
+	}
+
+	return &resolved, nil
+}
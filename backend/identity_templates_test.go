@@ -0,0 +1,137 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestIdentityTemplatedRoles(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+
+	sysView := b.System().(*logical.StaticSystemView)
+	sysView.EntityVal = &logical.Entity{
+		ID:       "entity-1",
+		Name:     "orders-app",
+		Metadata: map[string]string{"confluent_sa": "sa-orders"},
+	}
+	sysView.GroupsVal = []*logical.Group{
+		{ID: "group-1", Name: "payments", Metadata: map[string]string{"confluent_sa": "sa-payments"}},
+	}
+
+	creds := func(entityID, role string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + role,
+			Storage:   s,
+			EntityID:  entityID,
+		})
+	}
+
+	t.Run("Create Role without allowed_service_accounts - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "apps", map[string]interface{}{
+			"service_account": "{{identity.entity.metadata.confluent_sa}}",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "allowed_service_accounts")
+	})
+
+	t.Run("Create Role with invalid Template - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "apps", map[string]interface{}{
+			"service_account":          "{{identity.entity.metadata.confluent_sa",
+			"allowed_service_accounts": "sa-*",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Create Role", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "apps", map[string]interface{}{
+			"service_account":          "{{identity.entity.metadata.confluent_sa}}",
+			"allowed_service_accounts": "sa-orders,sa-pay*",
+			"key_display_name":         "vault-{{identity.entity.name}}",
+			"ttl":                      testTTL,
+			"max_ttl":                  testMaxTTL,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = testTokenRoleCreate(t, b, s, "groups", map[string]interface{}{
+			"service_account":          "{{identity.groups.names.payments.metadata.confluent_sa}}",
+			"allowed_service_accounts": "sa-orders",
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("Read Credentials", func(t *testing.T) {
+		resp, err := creds("entity-1", "apps")
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, "sa-orders", resp.Secret.InternalData["owner"])
+
+		spec, err := fake.getTokenSpec(context.Background(), resp.Data["api_key"].(string))
+		require.NoError(t, err)
+		require.Equal(t, "sa-orders", spec.Owner)
+		require.Equal(t, "vault-orders-app", spec.DisplayName)
+	})
+
+	t.Run("Read Credentials without Entity - fail", func(t *testing.T) {
+		resp, err := creds("", "apps")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Read Credentials with unresolvable Template - fail", func(t *testing.T) {
+		sysView.EntityVal.Metadata = nil
+		defer func() { sysView.EntityVal.Metadata = map[string]string{"confluent_sa": "sa-orders"} }()
+
+		resp, err := creds("entity-1", "apps")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Read Credentials for disallowed Service Account - fail", func(t *testing.T) {
+		resp, err := creds("entity-1", "groups")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Contains(t, resp.Error().Error(), `"sa-payments" is not allowed`)
+	})
+
+	t.Run("Renew after Metadata change", func(t *testing.T) {
+		resp, err := creds("entity-1", "apps")
+		require.NoError(t, err)
+
+		sysView.EntityVal.Metadata = map[string]string{"confluent_sa": "sa-payments"}
+		defer func() { sysView.EntityVal.Metadata = map[string]string{"confluent_sa": "sa-orders"} }()
+
+		resp.Secret.IssueTime = time.Now()
+		renewed, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, renewed.IsError())
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/apps",
+			Data:      map[string]interface{}{"allowed_service_accounts": "sa-pay*"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		renewed, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, renewed.IsError())
+		require.Contains(t, renewed.Error().Error(), "no longer allowed")
+	})
+}
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"strings"
+)
+
+const redactedLogValue = "[redacted]"
+
+// redactedLogKeys are log keys whose values are never written to the log.
+var redactedLogKeys = map[string]bool{
+	"api_secret":    true,
+	"password":      true,
+	"ldap_password": true,
+	"access_token":  true,
+	"token":         true,
+	"client_secret": true,
+}
+
+// redactLogArgs replaces the values of secret keys in hclog key/value pairs.
+func redactLogArgs(args []interface{}) []interface{} {
+	redacted := make([]interface{}, len(args))
+	copy(redacted, args)
+	for i := 0; i+1 < len(redacted); i += 2 {
+		if key, ok := redacted[i].(string); ok && redactedLogKeys[strings.ToLower(key)] {
+			redacted[i+1] = redactedLogValue
+		}
+	}
+	return redacted
+}
+
+// errorLogArgs returns the log key/value pairs describing err, including the
+// Confluent request ID when the error came from a Confluent API response.
+func errorLogArgs(err error) []interface{} {
+	args := []interface{}{"error", err}
+	if id := requestID(err); id != "" {
+		args = append(args, "request_id", id)
+	}
+	return args
+}
+
+func (b *Backend) log(level hclog.Level, msg string, args ...interface{}) {
+	b.Logger().Log(level, msg, redactLogArgs(args)...)
+}
+
+// logDebug logs a debug message. Mounts configured with debug=true log it at
+// info level instead, so it is visible without lowering Vault's log level.
+func (b *Backend) logDebug(msg string, args ...interface{}) {
+	level := hclog.Debug
+	if b.debug.Load() {
+		level = hclog.Info
+	}
+	b.log(level, msg, args...)
+}
+
+func (b *Backend) logInfo(msg string, args ...interface{}) {
+	b.log(hclog.Info, msg, args...)
+}
+
+func (b *Backend) logWarn(msg string, args ...interface{}) {
+	b.log(hclog.Warn, msg, args...)
+}
+
+func (b *Backend) logError(msg string, args ...interface{}) {
+	b.log(hclog.Error, msg, args...)
+}
@@ -0,0 +1,151 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robwittman/vault-plugin-secrets-confluent/internal/ccloudfake"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer that is safe to log to concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+// getLoggingTestBackend returns a backend that logs at info level to the
+// returned buffer.
+func getLoggingTestBackend(t *testing.T) (*Backend, logical.Storage, *syncBuffer) {
+	t.Helper()
+
+	out := new(syncBuffer)
+	config := logical.TestBackendConfig()
+	config.StorageView = new(logical.InmemStorage)
+	config.Logger = hclog.New(&hclog.LoggerOptions{Output: out, Level: hclog.Info})
+	config.System = logical.TestSystemView()
+
+	b, err := Factory(context.Background(), config)
+	require.NoError(t, err)
+	return b.(*Backend), config.StorageView, out
+}
+
+func TestLogging(t *testing.T) {
+	t.Run("Issue and Revoke", func(t *testing.T) {
+		b, s, out := getLoggingTestBackend(t)
+		fake := useFakeCloud(b)
+		fake.addServiceAccount("sa-123", "orders")
+
+		_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"service_account": "sa-123",
+		})
+		require.NoError(t, err)
+
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		logs := out.String()
+		require.Contains(t, logs, "issued API key")
+		require.Contains(t, logs, "revoked API key")
+		require.Contains(t, logs, resp.Data["api_key"].(string))
+		require.NotContains(t, logs, resp.Data["api_secret"].(string))
+		require.NotContains(t, logs, "confluent API call")
+	})
+
+	t.Run("Debug switch", func(t *testing.T) {
+		b, s, out := getLoggingTestBackend(t)
+
+		require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+			"username": username,
+			"password": password,
+			"debug":    true,
+		}))
+		require.NotContains(t, out.String(), password)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "config",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, true, resp.Data["debug"])
+
+		fake := useFakeCloud(b)
+		fake.addServiceAccount("sa-123", "orders")
+		_, err = testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"service_account": "sa-123",
+		})
+		require.NoError(t, err)
+		require.Contains(t, out.String(), "confluent API call")
+	})
+
+	t.Run("Confluent request ID", func(t *testing.T) {
+		b, s, out := getLoggingTestBackend(t)
+
+		srv := ccloudfake.NewServer(username, password)
+		defer srv.Close()
+		serviceAccountID := srv.AddServiceAccount("orders", "")
+
+		require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+			"username": username,
+			"password": password,
+			"url":      srv.URL,
+		}))
+		_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"service_account": serviceAccountID,
+		})
+		require.NoError(t, err)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Data:      map[string]interface{}{"password": "wrong"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		_, err = testCredentialsRead(b, s, "orders")
+		require.Error(t, err)
+		require.NotEmpty(t, requestID(err))
+		require.Contains(t, out.String(), "request_id="+requestID(err))
+		require.NotContains(t, out.String(), "wrong")
+	})
+}
+
+func TestRedactLogArgs(t *testing.T) {
+	args := []interface{}{"api_key", "KEY1", "api_secret", "s3cr3t", "Password", "hunter2", "role"}
+	redacted := redactLogArgs(args)
+
+	require.Equal(t, []interface{}{"api_key", "KEY1", "api_secret", redactedLogValue, "Password", redactedLogValue, "role"}, redacted)
+	require.Equal(t, "s3cr3t", args[3])
+}
+
+func TestErrorLogArgs(t *testing.T) {
+	require.Equal(t, []interface{}{"error", errors.New("boom")}, errorLogArgs(errors.New("boom")))
+
+	err := &confluentAPIError{StatusCode: 500, Err: errors.New("boom"), RequestID: "req-1"}
+	require.Equal(t, []interface{}{"error", err, "request_id", "req-1"}, errorLogArgs(err))
+}
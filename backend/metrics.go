@@ -0,0 +1,229 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/armon/go-metrics"
+	iamv2 "github.com/confluentinc/ccloud-sdk-go-v2/iam/v2"
+	"github.com/hashicorp/vault/sdk/logical"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+var (
+	metricAPIRequest     = []string{"confluent", "api", "request"}
+	metricAPIDuration    = []string{"confluent", "api", "duration"}
+	metricAPIRateLimited = []string{"confluent", "api", "rate_limited"}
+
+	metricCredsIssued  = []string{"confluent", "creds", "issued"}
+	metricCredsRenewed = []string{"confluent", "creds", "renewed"}
+	metricCredsRevoked = []string{"confluent", "creds", "revoked"}
+	metricCredsRotated = []string{"confluent", "creds", "rotated"}
+	metricCredsActive  = []string{"confluent", "creds", "active"}
+)
+
+// statusClass returns the status code class of a Confluent API call, such as
+// "2xx", or "error" when no response was received.
+func statusClass(err error) string {
+	if err == nil {
+		return "2xx"
+	}
+	var apiErr *confluentAPIError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("%dxx", apiErr.StatusCode/100)
+	}
+	return "error"
+}
+
+// measureAPICall records the outcome and duration of a Confluent API call.
+func measureAPICall(connection, operation string, start time.Time, err error) {
+	labels := []metrics.Label{
+		{Name: "operation", Value: operation},
+		{Name: "status", Value: statusClass(err)},
+		{Name: "connection", Value: connection},
+	}
+	metrics.IncrCounterWithLabels(metricAPIRequest, 1, labels)
+	metrics.MeasureSinceWithLabels(metricAPIDuration, start, labels)
+
+	var apiErr *confluentAPIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		metrics.IncrCounterWithLabels(metricAPIRateLimited, 1, []metrics.Label{
+			{Name: "operation", Value: operation},
+			{Name: "connection", Value: connection},
+		})
+	}
+}
+
+// meteredClient wraps a cloudClient, recording every call with
+// measureAPICall and logging it.
+type meteredClient struct {
+	cloudClient
+	b *Backend
+}
+
+var _ cloudClient = meteredClient{}
+
+func (m meteredClient) measure(operation string, start time.Time, err error) {
+	measureAPICall(m.connectionType(), operation, start, err)
+
+	args := []interface{}{
+		"operation", operation,
+		"status", statusClass(err),
+		"duration", time.Since(start).String(),
+	}
+	if err == nil {
+		m.b.logDebug("confluent API call", args...)
+		return
+	}
+
+	// Missing resources and rejected requests are handled by the callers,
+	// so only failures that may go away on their own are warnings.
+	args = append(args, errorLogArgs(err)...)
+	var apiErr *confluentAPIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode < http.StatusInternalServerError && apiErr.StatusCode != http.StatusTooManyRequests {
+		m.b.logDebug("confluent API call failed", args...)
+		return
+	}
+	m.b.logWarn("confluent API call failed", args...)
+}
+
+func (m meteredClient) createToken(ctx context.Context, keySpec apiKeySpec) (_ *confluentApiKey, err error) {
+	defer func(start time.Time) { m.measure("createToken", start, err) }(time.Now())
+	return m.cloudClient.createToken(ctx, keySpec)
+}
+
+func (m meteredClient) getTokenSpec(ctx context.Context, apiKeyId string) (_ *apiKeySpec, err error) {
+	defer func(start time.Time) { m.measure("getTokenSpec", start, err) }(time.Now())
+	return m.cloudClient.getTokenSpec(ctx, apiKeyId)
+}
+
+func (m meteredClient) listTokens(ctx context.Context, owner string) (_ []string, err error) {
+	defer func(start time.Time) { m.measure("listTokens", start, err) }(time.Now())
+	return m.cloudClient.listTokens(ctx, owner)
+}
+
+func (m meteredClient) verifyTokenSecret(ctx context.Context, apiKeyId, apiSecret string) (err error) {
+	defer func(start time.Time) { m.measure("verifyTokenSecret", start, err) }(time.Now())
+	return m.cloudClient.verifyTokenSecret(ctx, apiKeyId, apiSecret)
+}
+
+func (m meteredClient) deleteToken(ctx context.Context, apiKeyId string) (err error) {
+	defer func(start time.Time) { m.measure("deleteToken", start, err) }(time.Now())
+	return m.cloudClient.deleteToken(ctx, apiKeyId)
+}
+
+func (m meteredClient) listServiceAccounts(ctx context.Context) (_ []iamv2.IamV2ServiceAccount, err error) {
+	defer func(start time.Time) { m.measure("listServiceAccounts", start, err) }(time.Now())
+	return m.cloudClient.listServiceAccounts(ctx)
+}
+
+func (m meteredClient) getServiceAccount(ctx context.Context, id string) (_ *iamv2.IamV2ServiceAccount, err error) {
+	defer func(start time.Time) { m.measure("getServiceAccount", start, err) }(time.Now())
+	return m.cloudClient.getServiceAccount(ctx, id)
+}
+
+func (m meteredClient) createServiceAccount(ctx context.Context, displayName, description string) (_ *iamv2.IamV2ServiceAccount, err error) {
+	defer func(start time.Time) { m.measure("createServiceAccount", start, err) }(time.Now())
+	return m.cloudClient.createServiceAccount(ctx, displayName, description)
+}
+
+func (m meteredClient) updateServiceAccount(ctx context.Context, id, description string) (err error) {
+	defer func(start time.Time) { m.measure("updateServiceAccount", start, err) }(time.Now())
+	return m.cloudClient.updateServiceAccount(ctx, id, description)
+}
+
+func (m meteredClient) deleteServiceAccount(ctx context.Context, id string) (err error) {
+	defer func(start time.Time) { m.measure("deleteServiceAccount", start, err) }(time.Now())
+	return m.cloudClient.deleteServiceAccount(ctx, id)
+}
+
+func (m meteredClient) listUsers(ctx context.Context) (_ []iamv2.IamV2User, err error) {
+	defer func(start time.Time) { m.measure("listUsers", start, err) }(time.Now())
+	return m.cloudClient.listUsers(ctx)
+}
+
+func (m meteredClient) getUser(ctx context.Context, id string) (_ *iamv2.IamV2User, err error) {
+	defer func(start time.Time) { m.measure("getUser", start, err) }(time.Now())
+	return m.cloudClient.getUser(ctx, id)
+}
+
+func (m meteredClient) listRoleBindings(ctx context.Context, principal, roleName, crnPattern string) (_ []roleBinding, err error) {
+	defer func(start time.Time) { m.measure("listRoleBindings", start, err) }(time.Now())
+	return m.cloudClient.listRoleBindings(ctx, principal, roleName, crnPattern)
+}
+
+func (m meteredClient) createRoleBinding(ctx context.Context, binding roleBinding) (_ *roleBinding, err error) {
+	defer func(start time.Time) { m.measure("createRoleBinding", start, err) }(time.Now())
+	return m.cloudClient.createRoleBinding(ctx, binding)
+}
+
+func (m meteredClient) deleteRoleBinding(ctx context.Context, id string) (err error) {
+	defer func(start time.Time) { m.measure("deleteRoleBinding", start, err) }(time.Now())
+	return m.cloudClient.deleteRoleBinding(ctx, id)
+}
+
+// do is labelled with the HTTP method only, since paths contain resource IDs.
+func (m meteredClient) do(ctx context.Context, method, path string, query neturl.Values, body, out interface{}) (err error) {
+	defer func(start time.Time) { m.measure("rest_"+strings.ToLower(method), start, err) }(time.Now())
+	return m.cloudClient.do(ctx, method, path, query, body, out)
+}
+
+// credsIssued counts an API key issued for the role.
+func (b *Backend) credsIssued(role string) {
+	metrics.IncrCounterWithLabels(metricCredsIssued, 1, []metrics.Label{{Name: "role", Value: role}})
+}
+
+// credsRenewed counts a renewed API key lease of the role.
+func (b *Backend) credsRenewed(role string) {
+	metrics.IncrCounterWithLabels(metricCredsRenewed, 1, []metrics.Label{{Name: "role", Value: role}})
+}
+
+// credsRevoked counts an API key of the role that was revoked.
+func (b *Backend) credsRevoked(role string) {
+	metrics.IncrCounterWithLabels(metricCredsRevoked, 1, []metrics.Label{{Name: "role", Value: role}})
+}
+
+// credsRotated counts an API key of the role that was replaced by rotating
+// its lease.
+func (b *Backend) credsRotated(role string) {
+	metrics.IncrCounterWithLabels(metricCredsRotated, 1, []metrics.Label{{Name: "role", Value: role}})
+}
+
+// refreshActiveKeys sets the active keys gauge of every role from the lease
+// key records in storage, so the count survives restarts. Roles whose last
+// key was revoked are reported as zero once.
+func (b *Backend) refreshActiveKeys(ctx context.Context, req *logical.Request) error {
+	ids, err := req.Storage.List(ctx, leaseKeyStoragePrefix)
+	if err != nil {
+		return fmt.Errorf("error listing lease keys: %w", err)
+	}
+
+	active := map[string]int{}
+	for _, id := range ids {
+		key, err := getLeaseKey(ctx, req.Storage, id)
+		if err != nil {
+			return fmt.Errorf("error reading lease key %q: %w", id, err)
+		}
+		if key != nil {
+			active[key.Role]++
+		}
+	}
+
+	b.metricsLock.Lock()
+	defer b.metricsLock.Unlock()
+
+	for role := range b.activeKeys {
+		if _, ok := active[role]; !ok {
+			metrics.SetGaugeWithLabels(metricCredsActive, 0, []metrics.Label{{Name: "role", Value: role}})
+		}
+	}
+	for role, count := range active {
+		metrics.SetGaugeWithLabels(metricCredsActive, float32(count), []metrics.Label{{Name: "role", Value: role}})
+	}
+	b.activeKeys = active
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package backend
+
+import (
+	"context"
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useInmemMetrics routes the global metrics to an in-memory sink for the
+// duration of the test.
+func useInmemMetrics(t *testing.T) *metrics.InmemSink {
+	t.Helper()
+
+	sink := metrics.NewInmemSink(time.Hour, time.Hour)
+	config := metrics.DefaultConfig("")
+	config.EnableHostname = false
+	config.EnableRuntimeMetrics = false
+	_, err := metrics.NewGlobal(config, sink)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_, _ = metrics.NewGlobal(metrics.DefaultConfig(""), &metrics.BlackholeSink{})
+	})
+	return sink
+}
+
+// metricKey flattens a metric name and its labels the way the in-memory
+// sink does.
+func metricKey(name []string, labels ...string) string {
+	key := strings.Join(name, ".")
+	for i := 0; i+1 < len(labels); i += 2 {
+		key += ";" + labels[i] + "=" + labels[i+1]
+	}
+	return key
+}
+
+func counterValue(sink *metrics.InmemSink, key string) int {
+	for _, interval := range sink.Data() {
+		if counter, ok := interval.Counters[key]; ok {
+			return counter.Count
+		}
+	}
+	return 0
+}
+
+func gaugeValue(sink *metrics.InmemSink, key string) float32 {
+	for _, interval := range sink.Data() {
+		if gauge, ok := interval.Gauges[key]; ok {
+			return gauge.Value
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	sink := useInmemMetrics(t)
+
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+	fake.addServiceAccount("sa-123", "orders")
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+		"ttl":             testTTL,
+		"max_ttl":         testMaxTTL,
+	})
+	require.NoError(t, err)
+
+	fake.failNext("createToken", http.StatusTooManyRequests)
+	_, err = testCredentialsRead(b, s, "orders")
+	require.Error(t, err)
+
+	first, err := testCredentialsRead(b, s, "orders")
+	require.NoError(t, err)
+	_, err = testCredentialsRead(b, s, "orders")
+	require.NoError(t, err)
+
+	first.Secret.IssueTime = time.Now()
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Secret:    first.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    first.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 2, counterValue(sink, metricKey(metricAPIRequest,
+		"operation", "createToken", "status", "2xx", "connection", connectionTypeCloud)))
+	require.Equal(t, 1, counterValue(sink, metricKey(metricAPIRequest,
+		"operation", "createToken", "status", "4xx", "connection", connectionTypeCloud)))
+	require.Equal(t, 1, counterValue(sink, metricKey(metricAPIRateLimited,
+		"operation", "createToken", "connection", connectionTypeCloud)))
+
+	require.Equal(t, 2, counterValue(sink, metricKey(metricCredsIssued, "role", "orders")))
+	require.Equal(t, 1, counterValue(sink, metricKey(metricCredsRenewed, "role", "orders")))
+	require.Equal(t, 1, counterValue(sink, metricKey(metricCredsRevoked, "role", "orders")))
+
+	require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+	require.Equal(t, float32(1), gaugeValue(sink, metricKey(metricCredsActive, "role", "orders")))
+
+	t.Run("Active keys survive a restart", func(t *testing.T) {
+		sink := useInmemMetrics(t)
+		restarted, _ := getTestBackend(t)
+
+		require.NoError(t, restarted.refreshActiveKeys(context.Background(), &logical.Request{Storage: s}))
+		require.Equal(t, float32(1), gaugeValue(sink, metricKey(metricCredsActive, "role", "orders")))
+	})
+}
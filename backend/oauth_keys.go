@@ -0,0 +1,165 @@
+package backend
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/logical"
+	"time"
+)
+
+const (
+	oauthKeysStoragePath = "oauth/keys"
+
+	oauthKeyBits = 2048
+
+	// oauthKeyRetention is how long a retired signing key stays published,
+	// so tokens it signed can still be verified. Token lifetimes are capped
+	// to it.
+	oauthKeyRetention = 24 * time.Hour
+)
+
+type oauthSigningKey struct {
+	ID         string    `json:"id"`
+	PrivateKey []byte    `json:"private_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	RetiredAt  time.Time `json:"retired_at,omitempty"`
+}
+
+// oauthKeyRing holds the current signing key and any retired keys that are
+// still published in the JWKS. Next is published a rotation ahead of signing
+// with it, so Confluent has fetched it before tokens it signed show up.
+type oauthKeyRing struct {
+	Keys []*oauthSigningKey `json:"keys"`
+	Next *oauthSigningKey   `json:"next,omitempty"`
+}
+
+func getOAuthKeyRing(ctx context.Context, s logical.Storage) (*oauthKeyRing, error) {
+	entry, err := s.Get(ctx, oauthKeysStoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	ring := new(oauthKeyRing)
+	if entry == nil {
+		return ring, nil
+	}
+
+	if err := entry.DecodeJSON(ring); err != nil {
+		return nil, fmt.Errorf("error reading OAuth signing keys: %w", err)
+	}
+	return ring, nil
+}
+
+func setOAuthKeyRing(ctx context.Context, s logical.Storage, ring *oauthKeyRing) error {
+	entry, err := logical.StorageEntryJSON(oauthKeysStoragePath, ring)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// current returns the key new tokens are signed with, or nil if none exists.
+func (r *oauthKeyRing) current() *oauthSigningKey {
+	for _, key := range r.Keys {
+		if key.RetiredAt.IsZero() {
+			return key
+		}
+	}
+	return nil
+}
+
+// rotate retires the current key, starts signing with the next key, publishes
+// a new next key, and drops retired keys that are past their retention. When
+// no next key was published yet, it only publishes one.
+func (r *oauthKeyRing) rotate(now time.Time) error {
+	privateKey, err := rsa.GenerateKey(rand.Reader, oauthKeyBits)
+	if err != nil {
+		return fmt.Errorf("error generating OAuth signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return err
+	}
+
+	var keys []*oauthSigningKey
+	if r.Next != nil {
+		keys = append(keys, r.Next)
+	}
+	for _, key := range r.Keys {
+		if r.Next != nil && key.RetiredAt.IsZero() {
+			key.RetiredAt = now
+		}
+		if key.RetiredAt.IsZero() || now.Sub(key.RetiredAt) < oauthKeyRetention {
+			keys = append(keys, key)
+		}
+	}
+	r.Keys = keys
+	r.Next = &oauthSigningKey{ID: id, PrivateKey: der, CreatedAt: now}
+
+	return nil
+}
+
+// jwks returns the public keys of every key in the ring, including the next
+// key.
+func (r *oauthKeyRing) jwks() (*jose.JSONWebKeySet, error) {
+	keys := r.Keys
+	if r.Next != nil {
+		keys = append(keys[:len(keys):len(keys)], r.Next)
+	}
+
+	set := &jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(keys))}
+	for _, key := range keys {
+		privateKey, err := x509.ParsePKCS8PrivateKey(key.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing OAuth signing key %q: %w", key.ID, err)
+		}
+
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       privateKey.(*rsa.PrivateKey).Public(),
+			KeyID:     key.ID,
+			Algorithm: string(jose.RS256),
+			Use:       "sig",
+		})
+	}
+	return set, nil
+}
+
+// sign returns a JWT with the given claims signed by the current key.
+func (r *oauthKeyRing) sign(claims ...interface{}) (string, error) {
+	key := r.current()
+	if key == nil {
+		return "", fmt.Errorf("no OAuth signing key has been generated")
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("error parsing OAuth signing key %q: %w", key.ID, err)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.RS256,
+		Key:       jose.JSONWebKey{Key: privateKey, KeyID: key.ID},
+	}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	builder := jwt.Signed(signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+	return builder.Serialize()
+}
@@ -0,0 +1,431 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+	"time"
+)
+
+const (
+	approvalStoragePrefix = "approvals/"
+
+	// defaultApprovalTTL is how long a request waits for approval unless the
+	// role sets approval_ttl.
+	defaultApprovalTTL = time.Hour
+
+	// defaultApprovalWindow is how long an approved request can be used
+	// unless the role sets approval_window.
+	defaultApprovalWindow = 5 * time.Minute
+
+	approvalStatusPending  = "pending"
+	approvalStatusApproved = "approved"
+)
+
+// approvalRequest is a request for the credentials of a role that requires
+// approval by a second entity.
+type approvalRequest struct {
+	ID                  string    `json:"id"`
+	Role                string    `json:"role"`
+	EntityID            string    `json:"entity_id"`
+	DisplayName         string    `json:"display_name,omitempty"`
+	RemoteAddress       string    `json:"remote_address,omitempty"`
+	UserEmail           string    `json:"user_email,omitempty"`
+	RequestedAt         time.Time `json:"requested_at"`
+	ApproverEntityID    string    `json:"approver_entity_id,omitempty"`
+	ApproverDisplayName string    `json:"approver_display_name,omitempty"`
+	ApprovedAt          time.Time `json:"approved_at"`
+	ExpiresAt           time.Time `json:"expires_at"`
+
+	// InUse is set while credentials are being issued for the approved
+	// request, so it cannot be used twice at once.
+	InUse bool `json:"in_use,omitempty"`
+}
+
+func (a *approvalRequest) status() string {
+	if a.ApproverEntityID != "" {
+		return approvalStatusApproved
+	}
+	return approvalStatusPending
+}
+
+func (a *approvalRequest) expired() bool {
+	return !time.Now().Before(a.ExpiresAt)
+}
+
+func (a *approvalRequest) toResponseData() map[string]interface{} {
+	data := map[string]interface{}{
+		"id":             a.ID,
+		"role":           a.Role,
+		"status":         a.status(),
+		"entity_id":      a.EntityID,
+		"display_name":   a.DisplayName,
+		"remote_address": a.RemoteAddress,
+		"requested_at":   a.RequestedAt.Format(time.RFC3339),
+		"expires_at":     a.ExpiresAt.Format(time.RFC3339),
+	}
+	if a.UserEmail != "" {
+		data["user_email"] = a.UserEmail
+	}
+	if a.status() == approvalStatusApproved {
+		data["approver_entity_id"] = a.ApproverEntityID
+		data["approver_display_name"] = a.ApproverDisplayName
+		data["approved_at"] = a.ApprovedAt.Format(time.RFC3339)
+	}
+	return data
+}
+
+// approvalTTL returns how long requests for the role's credentials wait for
+// approval.
+func (r *confluentRoleEntry) approvalTTL() time.Duration {
+	if r.ApprovalTTL > 0 {
+		return r.ApprovalTTL
+	}
+	return defaultApprovalTTL
+}
+
+// approvalWindow returns how long an approved request for the role's
+// credentials can be used.
+func (r *confluentRoleEntry) approvalWindow() time.Duration {
+	if r.ApprovalWindow > 0 {
+		return r.ApprovalWindow
+	}
+	return defaultApprovalWindow
+}
+
+func pathApprovals(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "approvals/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathApprovalsList,
+				},
+			},
+			HelpSynopsis:    pathApprovalsListHelpSynopsis,
+			HelpDescription: pathApprovalsListHelpDescription,
+		},
+		{
+			Pattern: "approvals/" + framework.GenericNameRegex("id"),
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the approval request",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathApprovalRead,
+				},
+			},
+			HelpSynopsis:    pathApprovalsHelpSynopsis,
+			HelpDescription: pathApprovalsHelpDescription,
+		},
+		{
+			Pattern: "approvals/" + framework.GenericNameRegex("id") + "/approve$",
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the approval request",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathApprovalApprove,
+				},
+			},
+			HelpSynopsis:    pathApprovalApproveHelpSynopsis,
+			HelpDescription: pathApprovalApproveHelpDescription,
+		},
+	}
+}
+
+const pathApprovalsListHelpSynopsis = `List the pending and approved credential requests.`
+const pathApprovalsListHelpDescription = `
+Lists the IDs of the requests for credentials of roles with require_approval
+that have not expired or been used yet.
+`
+
+const pathApprovalsHelpSynopsis = `Read a credential request awaiting approval.`
+const pathApprovalsHelpDescription = `
+Returns the role, the requester and the status of a credential request, and
+when it expires.
+`
+
+const pathApprovalApproveHelpSynopsis = `Approve a credential request.`
+const pathApprovalApproveHelpDescription = `
+Approves a pending request for the credentials of a role with
+require_approval. The approver must be a different entity than the requester.
+Once approved, the requester reads creds/<role> with the approval_id within
+the role's approval_window. Grant update on this path only to approvers.
+`
+
+func getApproval(ctx context.Context, s logical.Storage, id string) (*approvalRequest, error) {
+	entry, err := s.Get(ctx, approvalStoragePrefix+id)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	approval := new(approvalRequest)
+	if err := entry.DecodeJSON(approval); err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+func setApproval(ctx context.Context, s logical.Storage, approval *approvalRequest) error {
+	entry, err := logical.StorageEntryJSON(approvalStoragePrefix+approval.ID, approval)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func (b *Backend) pathApprovalsList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ids, err := req.Storage.List(ctx, approvalStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(ids), nil
+}
+
+func (b *Backend) pathApprovalRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	approval, err := getApproval(ctx, req.Storage, d.Get("id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if approval == nil || approval.expired() {
+		return nil, nil
+	}
+
+	return &logical.Response{Data: approval.toResponseData()}, nil
+}
+
+func (b *Backend) pathApprovalApprove(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	id := d.Get("id").(string)
+
+	if req.EntityID == "" {
+		return logical.ErrorResponse("approving requests requires an entity"), logical.ErrPermissionDenied
+	}
+
+	b.approvalLock.Lock()
+	defer b.approvalLock.Unlock()
+
+	approval, err := getApproval(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if approval == nil || approval.expired() {
+		return logical.ErrorResponse("approval request %q does not exist or has expired", id), nil
+	}
+
+	if approval.status() != approvalStatusPending {
+		return logical.ErrorResponse("approval request %q was already approved", id), nil
+	}
+
+	if approval.EntityID == req.EntityID {
+		b.logWarn("denied self-approval of credentials request",
+			"approval_id", id,
+			"role", approval.Role,
+			"entity_id", req.EntityID)
+		return logical.ErrorResponse("requests must be approved by a different entity than the requester"), logical.ErrPermissionDenied
+	}
+
+	role, err := b.getRole(ctx, req.Storage, approval.Role)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if role == nil {
+		return logical.ErrorResponse("role %q no longer exists", approval.Role), nil
+	}
+
+	now := time.Now()
+	approval.ApproverEntityID = req.EntityID
+	approval.ApproverDisplayName = req.DisplayName
+	approval.ApprovedAt = now
+	approval.ExpiresAt = now.Add(role.approvalWindow())
+	if err := setApproval(ctx, req.Storage, approval); err != nil {
+		return nil, err
+	}
+
+	b.logInfo("approved credentials request",
+		"approval_id", id,
+		"role", approval.Role,
+		"entity_id", approval.EntityID,
+		"approver_entity_id", req.EntityID,
+		"approver_display_name", req.DisplayName)
+	b.sendEvent(ctx, req, eventApprovalApprove,
+		"approval_id", id,
+		"role", approval.Role,
+		"entity_id", approval.EntityID,
+		"approver_entity_id", req.EntityID)
+
+	return &logical.Response{Data: approval.toResponseData()}, nil
+}
+
+// requestApproval records a pending request for the role's credentials and
+// returns its ID instead of credentials.
+func (b *Backend) requestApproval(ctx context.Context, req *logical.Request, roleName string, role *confluentRoleEntry, userEmail string) (*logical.Response, error) {
+	if req.EntityID == "" {
+		return logical.ErrorResponse("role %q requires approval, which requires the requester to have an entity", roleName), logical.ErrPermissionDenied
+	}
+
+	if role.credentialType() == credentialTypeUser && userEmail == "" {
+		return logical.ErrorResponse("user_email is required for user roles"), nil
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	approval := &approvalRequest{
+		ID:            id,
+		Role:          roleName,
+		EntityID:      req.EntityID,
+		DisplayName:   req.DisplayName,
+		RemoteAddress: remoteAddress(req),
+		UserEmail:     userEmail,
+		RequestedAt:   now,
+		ExpiresAt:     now.Add(role.approvalTTL()),
+	}
+	if err := setApproval(ctx, req.Storage, approval); err != nil {
+		return nil, err
+	}
+
+	b.logInfo("requested approval for credentials",
+		"approval_id", id,
+		"role", roleName,
+		"entity_id", req.EntityID,
+		"display_name", req.DisplayName,
+		"remote_address", approval.RemoteAddress)
+	b.sendEvent(ctx, req, eventApprovalRequest,
+		"approval_id", id,
+		"role", roleName,
+		"entity_id", req.EntityID)
+
+	return &logical.Response{Data: map[string]interface{}{
+		"approval_id": id,
+		"status":      approval.status(),
+		"expires_at":  approval.ExpiresAt.Format(time.RFC3339),
+	}}, nil
+}
+
+// claimApproval checks that the approval request was approved for the
+// requester and the role, and marks it in use while credentials are issued.
+// It returns the approved request, or a response explaining why it cannot be
+// used. releaseApproval must be called once the credentials were issued or
+// failed.
+func (b *Backend) claimApproval(ctx context.Context, req *logical.Request, roleName, id, userEmail string) (*approvalRequest, *logical.Response, error) {
+	b.approvalLock.Lock()
+	defer b.approvalLock.Unlock()
+
+	approval, err := getApproval(ctx, req.Storage, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if approval == nil || approval.expired() || approval.Role != roleName {
+		return nil, logical.ErrorResponse("approval request %q does not exist or has expired", id), nil
+	}
+
+	if approval.EntityID != req.EntityID {
+		return nil, logical.ErrorResponse("approval request %q was made by a different entity", id), logical.ErrPermissionDenied
+	}
+
+	if approval.status() != approvalStatusApproved {
+		return nil, logical.ErrorResponse("approval request %q has not been approved yet", id), nil
+	}
+
+	if userEmail != "" && userEmail != approval.UserEmail {
+		return nil, logical.ErrorResponse("approval request %q was approved for a different user_email", id), nil
+	}
+
+	if approval.InUse {
+		return nil, logical.ErrorResponse("approval request %q is already being used", id), nil
+	}
+
+	approval.InUse = true
+	if err := setApproval(ctx, req.Storage, approval); err != nil {
+		return nil, nil, err
+	}
+	return approval, nil, nil
+}
+
+// releaseApproval deletes a claimed approval request once credentials were
+// issued for it. If issuing failed, the request can be used again within its
+// window, so the requester does not need a new approval.
+func (b *Backend) releaseApproval(ctx context.Context, req *logical.Request, approval *approvalRequest, issued bool) {
+	b.approvalLock.Lock()
+	defer b.approvalLock.Unlock()
+
+	var err error
+	if issued || approval.expired() {
+		err = req.Storage.Delete(ctx, approvalStoragePrefix+approval.ID)
+	} else {
+		approval.InUse = false
+		err = setApproval(ctx, req.Storage, approval)
+	}
+
+	// An approval left in use cannot be used again, and is deleted once it
+	// expires.
+	if err != nil {
+		b.logError("error releasing approval request",
+			append([]interface{}{"approval_id", approval.ID, "role", approval.Role, "issued", issued}, errorLogArgs(err)...)...)
+	}
+}
+
+// deleteExpiredApprovals deletes approval requests that expired before being
+// approved or used.
+func (b *Backend) deleteExpiredApprovals(ctx context.Context, req *logical.Request) error {
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary | consts.ReplicationPerformanceStandby) {
+		return nil
+	}
+
+	b.approvalLock.Lock()
+	defer b.approvalLock.Unlock()
+
+	ids, err := req.Storage.List(ctx, approvalStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, id := range ids {
+		approval, err := getApproval(ctx, req.Storage, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if approval == nil || !approval.expired() {
+			continue
+		}
+
+		if err := req.Storage.Delete(ctx, approvalStoragePrefix+id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		b.logDebug("deleted expired approval request",
+			"approval_id", id,
+			"role", approval.Role,
+			"status", approval.status())
+	}
+
+	return errors.Join(errs...)
+}
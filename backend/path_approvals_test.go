@@ -0,0 +1,136 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestApprovals(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+
+	_, err := testTokenRoleCreate(t, b, s, "admin", map[string]interface{}{
+		"service_account":  "sa-123",
+		"require_approval": true,
+		"approval_window":  60,
+	})
+	require.NoError(t, err)
+
+	creds := func(entityID string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/admin",
+			Data:      data,
+			Storage:   s,
+			EntityID:  entityID,
+		})
+	}
+
+	approve := func(entityID, id string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "approvals/" + id + "/approve",
+			Storage:   s,
+			EntityID:  entityID,
+		})
+	}
+
+	request := func(t *testing.T) string {
+		t.Helper()
+		resp, err := creds("requester", nil)
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Nil(t, resp.Secret)
+		require.Equal(t, approvalStatusPending, resp.Data["status"])
+		return resp.Data["approval_id"].(string)
+	}
+
+	tokens := func() []string {
+		ids, err := fake.listTokens(context.Background(), "sa-123")
+		require.NoError(t, err)
+		return ids
+	}
+
+	t.Run("Request without Entity - fail", func(t *testing.T) {
+		_, err := creds("", nil)
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+	})
+
+	t.Run("Create Bundle with approval Role - fail", func(t *testing.T) {
+		resp := testBundleWrite(t, b, s, "admins", map[string]interface{}{
+			"roles": "admin",
+		})
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "requires approval")
+	})
+
+	t.Run("Approve and Read Credentials", func(t *testing.T) {
+		id := request(t)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "approvals/",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Contains(t, resp.Data["keys"], id)
+
+		resp, err = creds("requester", map[string]interface{}{"approval_id": id})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "not been approved")
+
+		_, err = approve("requester", id)
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+
+		resp, err = approve("approver", id)
+		require.NoError(t, err)
+		require.Equal(t, approvalStatusApproved, resp.Data["status"])
+		require.Equal(t, "approver", resp.Data["approver_entity_id"])
+
+		resp, err = approve("other-approver", id)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		_, err = creds("other", map[string]interface{}{"approval_id": id})
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+
+		resp, err = creds("requester", map[string]interface{}{"approval_id": id})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, []string{resp.Data["api_key"].(string)}, tokens())
+
+		resp, err = creds("requester", map[string]interface{}{"approval_id": id})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Len(t, tokens(), 1)
+	})
+
+	t.Run("Expired Requests are cleaned up", func(t *testing.T) {
+		pending := request(t)
+		approved := request(t)
+		_, err := approve("approver", approved)
+		require.NoError(t, err)
+
+		approval, err := getApproval(context.Background(), s, approved)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(time.Minute), approval.ExpiresAt, 5*time.Second)
+
+		approval.ExpiresAt = time.Now().Add(-time.Second)
+		require.NoError(t, setApproval(context.Background(), s, approval))
+
+		resp, err := creds("requester", map[string]interface{}{"approval_id": approved})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "expired")
+
+		require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+
+		ids, err := s.List(context.Background(), approvalStoragePrefix)
+		require.NoError(t, err)
+		require.Equal(t, []string{pending}, ids)
+	})
+}
@@ -0,0 +1,392 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"time"
+)
+
+const (
+	bundleStoragePrefix = "bundle/"
+)
+
+// credentialBundle lists roles whose API keys are issued together under one
+// lease.
+type credentialBundle struct {
+	Roles  []string      `json:"roles"`
+	TTL    time.Duration `json:"ttl"`
+	MaxTTL time.Duration `json:"max_ttl"`
+}
+
+func (c *credentialBundle) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"roles":   c.Roles,
+		"ttl":     c.TTL.Seconds(),
+		"max_ttl": c.MaxTTL.Seconds(),
+	}
+}
+
+// leaseTTLs returns the lease of the bundle's keys: the shortest ttl and
+// max_ttl set on the bundle or any of its roles.
+func (c *credentialBundle) leaseTTLs(roles []*confluentRoleEntry) (time.Duration, time.Duration) {
+	ttl, maxTTL := c.TTL, c.MaxTTL
+	for _, role := range roles {
+		if role.TTL > 0 && (ttl == 0 || role.TTL < ttl) {
+			ttl = role.TTL
+		}
+		if role.MaxTTL > 0 && (maxTTL == 0 || role.MaxTTL < maxTTL) {
+			maxTTL = role.MaxTTL
+		}
+	}
+	return ttl, maxTTL
+}
+
+func pathBundles(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "bundle/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the bundle",
+					Required:    true,
+				},
+				"roles": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Roles whose API keys are issued together. Only roles issuing service account keys can be bundled.",
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Default lease of the bundle. The shortest ttl of the bundle and its roles is used.",
+				},
+				"max_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Maximum lease of the bundle. The shortest max_ttl of the bundle and its roles is used.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathBundlesRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathBundlesWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathBundlesWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathBundlesDelete,
+				},
+			},
+			ExistenceCheck:  b.pathBundlesExistenceCheck,
+			HelpSynopsis:    pathBundlesHelpSynopsis,
+			HelpDescription: pathBundlesHelpDescription,
+		},
+		{
+			Pattern: "bundle/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathBundlesList,
+				},
+			},
+			HelpSynopsis:    pathBundlesListHelpSynopsis,
+			HelpDescription: pathBundlesListHelpDescription,
+		},
+		{
+			Pattern: "creds-bundle/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the bundle",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathBundleCredentialsRead,
+				},
+			},
+			HelpSynopsis:    pathBundleCredentialsHelpSynopsis,
+			HelpDescription: pathBundleCredentialsHelpDescription,
+		},
+	}
+}
+
+const (
+	pathBundlesHelpSynopsis    = `Manages bundles of roles whose credentials are issued together.`
+	pathBundlesHelpDescription = `
+This path manages bundles. A bundle lists roles, and reading
+creds-bundle/<name> issues an API key for each of them under one lease.
+`
+	pathBundlesListHelpSynopsis    = `List the bundles of the Confluent backend`
+	pathBundlesListHelpDescription = `Bundles will be listed by name.`
+
+	pathBundleCredentialsHelpSynopsis    = `Generate the API keys of every role in a bundle.`
+	pathBundleCredentialsHelpDescription = `
+This path issues an API key for each role of the bundle, and returns them
+under one lease. If any key cannot be created, the keys already created are
+deleted and no credentials are returned. Revoking the lease deletes every
+key in it.
+`
+)
+
+func (b *Backend) pathBundlesExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, req.Path)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func getBundle(ctx context.Context, s logical.Storage, name string) (*credentialBundle, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing bundle name")
+	}
+
+	entry, err := s.Get(ctx, bundleStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	bundle := new(credentialBundle)
+	if err := entry.DecodeJSON(bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+func setBundle(ctx context.Context, s logical.Storage, name string, bundle *credentialBundle) error {
+	entry, err := logical.StorageEntryJSON(bundleStoragePrefix+name, bundle)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func (b *Backend) pathBundlesRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	bundle, err := getBundle(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if bundle == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: bundle.toResponseData(),
+	}, nil
+}
+
+func (b *Backend) pathBundlesWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	bundle, err := getBundle(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if bundle == nil {
+		if req.Operation != logical.CreateOperation {
+			return nil, errors.New("bundle not found during update operation")
+		}
+		bundle = new(credentialBundle)
+	}
+
+	if roles, ok := d.GetOk("roles"); ok {
+		bundle.Roles = strutil.RemoveDuplicatesStable(roles.([]string), true)
+	}
+
+	if ttl, ok := d.GetOk("ttl"); ok {
+		bundle.TTL = time.Duration(ttl.(int)) * time.Second
+	}
+
+	if maxTTL, ok := d.GetOk("max_ttl"); ok {
+		bundle.MaxTTL = time.Duration(maxTTL.(int)) * time.Second
+	}
+
+	if len(bundle.Roles) == 0 {
+		return logical.ErrorResponse("roles is required"), nil
+	}
+
+	if bundle.MaxTTL != 0 && bundle.TTL > bundle.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+
+	if _, err := b.getBundleRoles(ctx, req.Storage, name, bundle); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if err := setBundle(ctx, req.Storage, name, bundle); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathBundlesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, bundleStoragePrefix+d.Get("name").(string)); err != nil {
+		return nil, fmt.Errorf("error deleting bundle: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathBundlesList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, bundleStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+// getBundleRoles returns the roles of the bundle, in the bundle's order.
+func (b *Backend) getBundleRoles(ctx context.Context, s logical.Storage, bundleName string, bundle *credentialBundle) ([]*confluentRoleEntry, error) {
+	roles := make([]*confluentRoleEntry, 0, len(bundle.Roles))
+	for _, roleName := range bundle.Roles {
+		role, err := b.getRole(ctx, s, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving role %q: %w", roleName, err)
+		}
+
+		if role == nil {
+			return nil, fmt.Errorf("role %q of bundle %q does not exist", roleName, bundleName)
+		}
+
+		if !role.requiresServiceAccount() {
+			return nil, fmt.Errorf("role %q issues %s credentials, which cannot be bundled", roleName, role.credentialType())
+		}
+
+		if role.RequireApproval {
+			return nil, fmt.Errorf("role %q requires approval, and cannot be bundled", roleName)
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (b *Backend) pathBundleCredentialsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	bundle, err := getBundle(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if bundle == nil {
+		return logical.ErrorResponse("bundle %q does not exist", name), nil
+	}
+
+	roles, err := b.getBundleRoles(ctx, req.Storage, name, bundle)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Identity templates are resolved, and the organization ID looked up,
+	// before any key is created.
+	organizationID := ""
+	for i, role := range roles {
+		if resp, err := b.denyRequester(req, bundle.Roles[i], role); resp != nil || err != nil {
+			return resp, err
+		}
+
+		if roles[i], err = b.withIdentity(req, role); err != nil {
+			return logical.ErrorResponse("role %q: %s", bundle.Roles[i], err), logical.ErrPermissionDenied
+		}
+
+		if role.credentialType() == credentialTypeFlink && organizationID == "" {
+			c, err := b.getClient(ctx, req.Storage)
+			if err != nil {
+				return nil, err
+			}
+			if organizationID, err = getOrganizationID(ctx, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var keys []bundleKey
+	credentials := map[string]interface{}{}
+	for i, role := range roles {
+		roleName := bundle.Roles[i]
+		spec := role.keySpec()
+
+		apiKey, err := b.createApiKey(ctx, req.Storage, role, spec)
+		if err != nil {
+			b.logError("error issuing API key of bundle, deleting the bundle's other keys",
+				append([]interface{}{"bundle", name, "role", roleName}, errorLogArgs(err)...)...)
+			if cleanupErr := b.deleteBundleKeys(ctx, req, keys); cleanupErr != nil {
+				b.logError("error deleting API keys of failed bundle",
+					append([]interface{}{"bundle", name}, errorLogArgs(cleanupErr)...)...)
+			}
+			return nil, fmt.Errorf("error issuing API key for role %q of bundle %q: %w", roleName, name, err)
+		}
+
+		keys = append(keys, bundleKey{
+			Role:     roleName,
+			APIKey:   apiKey.ApiKey,
+			Owner:    spec.Owner,
+			Resource: spec.Resource,
+		})
+
+		// The record counts the key as active, like keys of single leases.
+		err = setLeaseKey(ctx, req.Storage, apiKey.ApiKey, &leaseKey{
+			Role:                roleName,
+			Spec:                spec,
+			EntityID:            req.EntityID,
+			ClientTokenAccessor: req.ClientTokenAccessor,
+			APIKey:              apiKey.ApiKey,
+		})
+		if err != nil {
+			if cleanupErr := b.deleteBundleKeys(ctx, req, keys); cleanupErr != nil {
+				b.logError("error deleting API keys of failed bundle",
+					append([]interface{}{"bundle", name}, errorLogArgs(cleanupErr)...)...)
+			}
+			return nil, fmt.Errorf("error recording API key for role %q of bundle %q: %w", roleName, name, err)
+		}
+
+		data := map[string]interface{}{
+			"api_key":    apiKey.ApiKey,
+			"api_secret": apiKey.ApiSecret,
+		}
+		for k, v := range role.connectionInfo(organizationID, apiKey) {
+			data[k] = v
+		}
+		credentials[roleName] = data
+	}
+
+	resp := b.Secret(ConfluentBundleKeyType).Response(map[string]interface{}{
+		"credentials": credentials,
+	}, map[string]interface{}{
+		"bundle": name,
+		"keys":   keys,
+	})
+	resp.Secret.TTL, resp.Secret.MaxTTL = bundle.leaseTTLs(roles)
+
+	for _, key := range keys {
+		b.credsIssued(key.Role)
+		b.sendEvent(ctx, req, eventCredsCreate,
+			"role", key.Role,
+			"bundle", name,
+			"api_key", key.APIKey,
+			"owner", key.Owner,
+			"resource", key.Resource)
+	}
+	b.logInfo("issued API key bundle",
+		"bundle", name,
+		"keys", len(keys),
+		"ttl", resp.Secret.TTL.String())
+
+	return resp, nil
+}
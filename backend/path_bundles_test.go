@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func testBundleWrite(t *testing.T, b *Backend, s logical.Storage, name string, data map[string]interface{}) *logical.Response {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "bundle/" + name,
+		Data:      data,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	return resp
+}
+
+func testBundleCredentialsRead(b *Backend, s logical.Storage, name string) (*logical.Response, error) {
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds-bundle/" + name,
+		Storage:   s,
+	})
+}
+
+func TestCredentialBundles(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+
+	for name, serviceAccount := range map[string]string{"orders": "sa-123", "payments": "sa-456", "invoices": "sa-789"} {
+		_, err := testTokenRoleCreate(t, b, s, name, map[string]interface{}{
+			"service_account": serviceAccount,
+			"ttl":             testTTL,
+			"max_ttl":         testMaxTTL,
+		})
+		require.NoError(t, err)
+	}
+
+	_, err := testTokenRoleCreate(t, b, s, "break-glass", map[string]interface{}{
+		"credential_type":  credentialTypeUser,
+		"allowed_user_ids": "u-123",
+		"skip_validation":  true,
+	})
+	require.NoError(t, err)
+
+	tokens := func(owner string) []string {
+		ids, err := fake.listTokens(context.Background(), owner)
+		require.NoError(t, err)
+		return ids
+	}
+
+	t.Run("Create Bundle with missing Role - fail", func(t *testing.T) {
+		resp := testBundleWrite(t, b, s, "streaming", map[string]interface{}{
+			"roles": "orders,missing",
+		})
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Create Bundle with user Role - fail", func(t *testing.T) {
+		resp := testBundleWrite(t, b, s, "streaming", map[string]interface{}{
+			"roles": "orders,break-glass",
+		})
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "cannot be bundled")
+	})
+
+	require.Nil(t, testBundleWrite(t, b, s, "streaming", map[string]interface{}{
+		"roles": "orders,payments,invoices",
+		"ttl":   60,
+	}))
+
+	t.Run("Read Credentials", func(t *testing.T) {
+		resp, err := testBundleCredentialsRead(b, s, "streaming")
+		require.NoError(t, err)
+		require.Equal(t, 60*time.Second, resp.Secret.TTL)
+		require.Equal(t, time.Duration(testMaxTTL)*time.Second, resp.Secret.MaxTTL)
+
+		credentials := resp.Data["credentials"].(map[string]interface{})
+		require.Len(t, credentials, 3)
+		orders := credentials["orders"].(map[string]interface{})
+		require.Equal(t, []string{orders["api_key"].(string)}, tokens("sa-123"))
+
+		resp.Secret.IssueTime = time.Now()
+		renewed, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, renewed.IsError())
+		require.Equal(t, 60*time.Second, renewed.Secret.TTL)
+
+		records, err := s.List(context.Background(), leaseKeyStoragePrefix)
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Empty(t, tokens("sa-123"))
+		require.Empty(t, tokens("sa-456"))
+		require.Empty(t, tokens("sa-789"))
+
+		records, err = s.List(context.Background(), leaseKeyStoragePrefix)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+
+	t.Run("Read Credentials with failed Key - fail", func(t *testing.T) {
+		fake.failNext("createToken", 0, 0, http.StatusInternalServerError)
+
+		_, err := testBundleCredentialsRead(b, s, "streaming")
+		require.Error(t, err)
+		require.Empty(t, tokens("sa-123"))
+		require.Empty(t, tokens("sa-456"))
+		require.Empty(t, tokens("sa-789"))
+
+		records, err := s.List(context.Background(), leaseKeyStoragePrefix)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+
+	t.Run("Revoke retries Keys that remain", func(t *testing.T) {
+		resp, err := testBundleCredentialsRead(b, s, "streaming")
+		require.NoError(t, err)
+
+		fake.failNext("deleteToken", 0, http.StatusServiceUnavailable)
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.Error(t, err)
+		require.Len(t, tokens("sa-456"), 1)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Empty(t, tokens("sa-456"))
+	})
+}
@@ -13,10 +13,25 @@ const (
 )
 
 type clientConfig struct {
-	URL         string `json:"url"`
-	AccessToken string `json:"access_token,omitempty"`
-	Username    string `json:"username,omitempty"`
-	Password    string `json:"password,omitempty"`
+	ConnectionType string `json:"connection_type,omitempty"`
+	URL            string `json:"url"`
+	AccessToken    string `json:"access_token,omitempty"`
+	Username       string `json:"username,omitempty"`
+	Password       string `json:"password,omitempty"`
+
+	OrganizationID string `json:"organization_id,omitempty"`
+
+	Debug bool `json:"debug,omitempty"`
+}
+
+// connectionType returns the kind of Confluent deployment the engine talks
+// to. Configurations written before connection types were introduced use
+// Confluent Cloud.
+func (c *clientConfig) connectionType() string {
+	if c.ConnectionType == "" {
+		return connectionTypeCloud
+	}
+	return c.ConnectionType
 }
 
 func getConfig(ctx context.Context, s logical.Storage) (*clientConfig, error) {
@@ -68,6 +83,24 @@ func pathConfig(b *Backend) *framework.Path {
 					Sensitive: true,
 				},
 			},
+			"organization_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the Confluent organization. Looked up from the credentials if not set.",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "Organization ID",
+					Sensitive: false,
+				},
+			},
+			"connection_type": {
+				Type:          framework.TypeString,
+				Description:   "Kind of Confluent deployment to manage: cloud, or platform for a self-managed Confluent Platform Metadata Service (MDS).",
+				AllowedValues: []interface{}{connectionTypeCloud, connectionTypePlatform},
+			},
+			"debug": {
+				Type:        framework.TypeBool,
+				Description: "Log this mount's debug messages, such as every Confluent API call, at info level so they are visible without lowering Vault's log level.",
+			},
 			"url": {
 				Type:        framework.TypeString,
 				Description: "The URL for the HashiCups Product API",
@@ -120,12 +153,26 @@ func (b *Backend) pathConfigRead(ctx context.Context, req *logical.Request, data
 		return nil, err
 	}
 
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: map[string]interface{}{
 			"username": config.Username,
 			"url":      config.URL,
 		},
-	}, nil
+	}
+
+	if config.OrganizationID != "" {
+		resp.Data["organization_id"] = config.OrganizationID
+	}
+
+	if config.ConnectionType != "" {
+		resp.Data["connection_type"] = config.ConnectionType
+	}
+
+	if config.Debug {
+		resp.Data["debug"] = true
+	}
+
+	return resp, nil
 }
 
 func (b *Backend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -148,10 +195,22 @@ func (b *Backend) pathConfigWrite(ctx context.Context, req *logical.Request, dat
 		config.Username = username.(string)
 	}
 
+	if connectionType, ok := data.GetOk("connection_type"); ok {
+		config.ConnectionType = connectionType.(string)
+	}
+
 	if url, ok := data.GetOk("url"); ok {
 		config.URL = url.(string)
 	}
 
+	if organizationID, ok := data.GetOk("organization_id"); ok {
+		config.OrganizationID = organizationID.(string)
+	}
+
+	if debug, ok := data.GetOk("debug"); ok {
+		config.Debug = debug.(bool)
+	}
+
 	if password, ok := data.GetOk("password"); ok {
 		config.Password = password.(string)
 		authProvided = true
@@ -166,6 +225,10 @@ func (b *Backend) pathConfigWrite(ctx context.Context, req *logical.Request, dat
 		return nil, fmt.Errorf("authentication missing in configuration")
 	}
 
+	if config.connectionType() == connectionTypePlatform && config.URL == "" {
+		return logical.ErrorResponse("url of the Metadata Service is required for connection_type %q", connectionTypePlatform), nil
+	}
+
 	entry, err := logical.StorageEntryJSON(configStoragePath, config)
 	if err != nil {
 		return nil, err
@@ -176,6 +239,17 @@ func (b *Backend) pathConfigWrite(ctx context.Context, req *logical.Request, dat
 	}
 
 	b.reset()
+	b.debug.Store(config.Debug)
+
+	b.logInfo("configuration updated",
+		"connection_type", config.connectionType(),
+		"url", config.URL,
+		"username", config.Username,
+		"debug", config.Debug)
+
+	b.sendEvent(ctx, req, eventConfigWrite,
+		"connection_type", config.connectionType(),
+		"url", config.URL)
 
 	return nil, nil
 }
@@ -185,6 +259,8 @@ func (b *Backend) pathConfigDelete(ctx context.Context, req *logical.Request, da
 
 	if err == nil {
 		b.reset()
+		b.debug.Store(false)
+		b.logInfo("configuration deleted")
 	}
 
 	return nil, err
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"time"
 )
 
 func pathCredentials(b *Backend) *framework.Path {
@@ -17,6 +19,14 @@ func pathCredentials(b *Backend) *framework.Path {
 				Description: "Name of the role",
 				Required:    true,
 			},
+			"user_email": {
+				Type:        framework.TypeString,
+				Description: "Email address of the user account a break-glass key is issued for. Required by user roles.",
+			},
+			"approval_id": {
+				Type:        framework.TypeString,
+				Description: "ID of an approved request, for roles with require_approval. Without it, a new request is made.",
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
@@ -40,15 +50,21 @@ This path generates Confluent API Keys for a particular service
 account. A role can only represent a single service account
 `
 
-func (b *Backend) createApiKey(ctx context.Context, s logical.Storage, roleEntry *confluentRoleEntry) (*confluentApiKey, error) {
+func (b *Backend) createApiKey(ctx context.Context, s logical.Storage, roleEntry *confluentRoleEntry, spec apiKeySpec) (*confluentApiKey, error) {
 	client, err := b.getClient(ctx, s)
 	if err != nil {
 		return nil, err
 	}
 
+	if roleEntry.credentialType() == credentialTypeSchemaRegistry {
+		if err := ensureSubjectBindings(ctx, client, roleEntry); err != nil {
+			return nil, fmt.Errorf("error granting Schema Registry subject bindings: %w", err)
+		}
+	}
+
 	var apiKey *confluentApiKey
 
-	apiKey, err = createToken(ctx, client, roleEntry.ServiceAccount)
+	apiKey, err = client.createToken(ctx, spec)
 	if err != nil {
 		return nil, fmt.Errorf("error creating Confluent API Key: %w", err)
 	}
@@ -60,29 +76,90 @@ func (b *Backend) createApiKey(ctx context.Context, s logical.Storage, roleEntry
 	return apiKey, nil
 }
 
-func (b *Backend) createRoleCreds(ctx context.Context, req *logical.Request, role *confluentRoleEntry) (*logical.Response, error) {
-	apiKey, err := b.createApiKey(ctx, req.Storage, role)
+func (b *Backend) createRoleCreds(ctx context.Context, req *logical.Request, roleName string, role *confluentRoleEntry, spec apiKeySpec) (*logical.Response, error) {
+	// Flink clients need the organization ID, which is looked up before the
+	// key is created so a failed lookup does not leave an orphaned key.
+	organizationID := ""
+	if role.credentialType() == credentialTypeFlink {
+		c, err := b.getClient(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if organizationID, err = getOrganizationID(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+
+	apiKey, err := b.createApiKey(ctx, req.Storage, role, spec)
 	if err != nil {
+		b.logError("error issuing API key",
+			append([]interface{}{"role", roleName, "owner", spec.Owner, "resource", spec.Resource}, errorLogArgs(err)...)...)
 		return nil, err
 	}
 
-	resp := b.Secret(ConfluentApiKeyType).Response(map[string]interface{}{
+	data := map[string]interface{}{
 		"api_key":    apiKey.ApiKey,
 		"api_secret": apiKey.ApiSecret,
-	}, map[string]interface{}{
+	}
+	for k, v := range role.connectionInfo(organizationID, apiKey) {
+		data[k] = v
+	}
+
+	resp, err := b.leaseAPIKey(ctx, req, roleName, spec, apiKey, data, role.TTL, role.MaxTTL)
+	if err != nil {
+		if cleanupErr := b.deleteAPIKey(ctx, req.Storage, roleName, apiKey.ApiKey); cleanupErr != nil {
+			b.logError("error deleting API key after failing to record it",
+				append([]interface{}{"role", roleName, "api_key", apiKey.ApiKey}, errorLogArgs(cleanupErr)...)...)
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// leaseAPIKey records an API key for a new lease and returns the lease. The
+// record lets the lease be rotated later.
+func (b *Backend) leaseAPIKey(ctx context.Context, req *logical.Request, roleName string, spec apiKeySpec, apiKey *confluentApiKey, data map[string]interface{}, ttl, maxTTL time.Duration) (*logical.Response, error) {
+	err := setLeaseKey(ctx, req.Storage, apiKey.ApiKey, &leaseKey{
+		Role:                roleName,
+		Spec:                spec,
+		EntityID:            req.EntityID,
+		ClientTokenAccessor: req.ClientTokenAccessor,
+		APIKey:              apiKey.ApiKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error recording API key: %w", err)
+	}
+
+	resp := b.Secret(ConfluentApiKeyType).Response(data, map[string]interface{}{
 		"api_key":    apiKey.ApiKey,
 		"api_secret": apiKey.ApiSecret,
-		"role":       role.ServiceAccount,
+		"role":       roleName,
+		"owner":      spec.Owner,
+		"resource":   spec.Resource,
 	})
 
-	if role.TTL > 0 {
-		resp.Secret.TTL = role.TTL
+	if ttl > 0 {
+		resp.Secret.TTL = ttl
 	}
 
-	if role.MaxTTL > 0 {
-		resp.Secret.MaxTTL = role.MaxTTL
+	if maxTTL > 0 {
+		resp.Secret.MaxTTL = maxTTL
 	}
 
+	b.credsIssued(roleName)
+	b.logInfo("issued API key",
+		"role", roleName,
+		"api_key", apiKey.ApiKey,
+		"owner", spec.Owner,
+		"resource", spec.Resource,
+		"ttl", resp.Secret.TTL.String())
+	b.sendEvent(ctx, req, eventCredsCreate,
+		"role", roleName,
+		"api_key", apiKey.ApiKey,
+		"owner", spec.Owner,
+		"resource", spec.Resource)
+
 	return resp, nil
 }
 
@@ -98,5 +175,114 @@ func (b *Backend) pathCredentialsRead(ctx context.Context, req *logical.Request,
 		return nil, errors.New("error retrieving role: role is nil")
 	}
 
-	return b.createRoleCreds(ctx, req, roleEntry)
+	if resp, err := b.denyRequester(req, roleName, roleEntry); resp != nil || err != nil {
+		return resp, err
+	}
+
+	userEmail := d.Get("user_email").(string)
+
+	// Roles requiring approval return a request ID first. Credentials are
+	// only issued for an approved request, which is used up once they are.
+	if roleEntry.RequireApproval {
+		approvalID := d.Get("approval_id").(string)
+		if approvalID == "" {
+			return b.requestApproval(ctx, req, roleName, roleEntry, userEmail)
+		}
+
+		approval, resp, err := b.claimApproval(ctx, req, roleName, approvalID, userEmail)
+		if resp != nil || err != nil {
+			return resp, err
+		}
+
+		// User roles issue the key for the user the approver saw.
+		resp, err = b.issueCredentials(ctx, req, roleName, roleEntry, approval.UserEmail)
+		b.releaseApproval(ctx, req, approval, err == nil && !resp.IsError())
+		return resp, err
+	}
+
+	return b.issueCredentials(ctx, req, roleName, roleEntry, userEmail)
+}
+
+// issueCredentials issues the credentials of the role to the requester.
+func (b *Backend) issueCredentials(ctx context.Context, req *logical.Request, roleName string, roleEntry *confluentRoleEntry, userEmail string) (*logical.Response, error) {
+	if roleEntry.credentialType() == credentialTypeOAuth {
+		return b.createOAuthToken(ctx, req, roleEntry)
+	}
+
+	if isPlatformCredentialType(roleEntry.credentialType()) {
+		return b.createPlatformCreds(ctx, req, roleEntry)
+	}
+
+	// Templated service accounts and display names are resolved from the
+	// requester's identity. The resolved owner is kept in the lease.
+	roleEntry, err := b.withIdentity(req, roleEntry)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrPermissionDenied
+	}
+
+	spec := roleEntry.keySpec()
+
+	if roleEntry.credentialType() == credentialTypeUser {
+		if userEmail == "" {
+			return logical.ErrorResponse("user_email is required for user roles"), nil
+		}
+
+		userID, err := b.resolveBreakGlassUser(ctx, req.Storage, roleEntry, userEmail)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrPermissionDenied
+		}
+
+		spec.Owner = userID
+		spec.OwnerKind = userOwnerKind
+		spec.DisplayName = "Vault break-glass key"
+		spec.Description = fmt.Sprintf("Vault break-glass key for %s issued to %s via role %s", userEmail, req.DisplayName, roleName)
+	} else if userEmail != "" {
+		return logical.ErrorResponse("user_email can only be used with user roles"), nil
+	}
+
+	resp, err := b.createRoleCreds(ctx, req, roleName, roleEntry, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if roleEntry.credentialType() == credentialTypeUser {
+		b.logWarn("issued break-glass API key owned by a user account",
+			"role", roleName,
+			"user_id", spec.Owner,
+			"user_email", userEmail,
+			"api_key", resp.Data["api_key"],
+			"ttl", resp.Secret.TTL.String(),
+			"entity_id", req.EntityID,
+			"display_name", req.DisplayName,
+			"client_token_accessor", req.ClientTokenAccessor,
+			"remote_address", remoteAddress(req))
+	}
+
+	return resp, nil
+}
+
+// resolveBreakGlassUser looks up the user account with the given email and
+// checks that the role may issue keys for it.
+func (b *Backend) resolveBreakGlassUser(ctx context.Context, s logical.Storage, roleEntry *confluentRoleEntry, email string) (string, error) {
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := findUserByEmail(ctx, c, email)
+	if err != nil {
+		return "", err
+	}
+
+	if !strutil.StrListContains(roleEntry.AllowedUserIDs, userID) {
+		return "", fmt.Errorf("user %q (%s) is not allowed by this role", email, userID)
+	}
+	return userID, nil
+}
+
+func remoteAddress(req *logical.Request) string {
+	if req.Connection == nil {
+		return ""
+	}
+	return req.Connection.RemoteAddr
 }
@@ -0,0 +1,431 @@
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+	"strings"
+	"time"
+)
+
+const (
+	leaseKeyStoragePrefix        = "lease-keys/"
+	leaseIDStoragePrefix         = "lease-ids/"
+	pendingDeletionStoragePrefix = "pending-deletions/"
+
+	defaultRotationOverlap = 5 * time.Minute
+	maxRotationOverlap     = 24 * time.Hour
+)
+
+// leaseKey tracks the API key currently behind a lease, so the key can be
+// rotated without changing the lease. It is stored under the ID of the key
+// the lease was issued with, which revocation and renewal still see.
+type leaseKey struct {
+	Role                string     `json:"role"`
+	Spec                apiKeySpec `json:"spec"`
+	EntityID            string     `json:"entity_id,omitempty"`
+	ClientTokenAccessor string     `json:"client_token_accessor,omitempty"`
+
+	// LeaseID is the hashed ID of the lease the key was issued under. Vault
+	// assigns lease IDs after the engine responds, so it is bound from the
+	// first renewal, which carries it.
+	LeaseID string `json:"lease_id,omitempty"`
+
+	// APIKey is the current key of the lease, and RetiredAPIKeys the keys
+	// it replaced that may not have been deleted yet.
+	APIKey         string   `json:"api_key"`
+	RetiredAPIKeys []string `json:"retired_api_keys,omitempty"`
+}
+
+// isOwner reports whether the request was made by the identity the lease was
+// issued to.
+func (k *leaseKey) isOwner(req *logical.Request) bool {
+	if k.EntityID != "" {
+		return k.EntityID == req.EntityID
+	}
+	return k.ClientTokenAccessor != "" && k.ClientTokenAccessor == req.ClientTokenAccessor
+}
+
+// pendingDeletion is an API key that is deleted once its overlap window
+// after a rotation has passed.
+type pendingDeletion struct {
+	APIKey      string    `json:"api_key"`
+	Role        string    `json:"role"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+func hashLeaseID(leaseID string) string {
+	sum := sha256.Sum256([]byte(leaseID))
+	return hex.EncodeToString(sum[:])
+}
+
+func getLeaseKey(ctx context.Context, s logical.Storage, apiKeyId string) (*leaseKey, error) {
+	entry, err := s.Get(ctx, leaseKeyStoragePrefix+apiKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	key := new(leaseKey)
+	if err := entry.DecodeJSON(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func setLeaseKey(ctx context.Context, s logical.Storage, apiKeyId string, key *leaseKey) error {
+	entry, err := logical.StorageEntryJSON(leaseKeyStoragePrefix+apiKeyId, key)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		return fmt.Errorf("failed to create storage entry for lease key")
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func setPendingDeletion(ctx context.Context, s logical.Storage, pending *pendingDeletion) error {
+	entry, err := logical.StorageEntryJSON(pendingDeletionStoragePrefix+pending.APIKey, pending)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		return fmt.Errorf("failed to create storage entry for pending deletion")
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func pathCredentialsRotate(b *Backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name") + "/rotate",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+			"lease_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the lease whose API key is rotated, issued by creds/ or import/ for the role.",
+				Required:    true,
+			},
+			"api_key": {
+				Type:        framework.TypeString,
+				Description: "ID of the API key currently held for the lease.",
+				Required:    true,
+			},
+			"overlap": {
+				Type:        framework.TypeDurationSecond,
+				Description: "How long the replaced key keeps working after the rotation. Defaults to 5 minutes, and cannot exceed 24 hours.",
+				Default:     int(defaultRotationOverlap.Seconds()),
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathCredentialsRotate,
+			},
+		},
+		HelpSynopsis:    pathCredentialsRotateHelpSyn,
+		HelpDescription: pathCredentialsRotateHelpDesc,
+	}
+}
+
+const pathCredentialsRotateHelpSyn = `
+Replace the API key behind an existing lease.
+`
+
+const pathCredentialsRotateHelpDesc = `
+This path issues a new API key for the same owner and resource as the key
+behind a lease of the role, and returns it. The lease keeps its ID, and
+revoking it deletes the new key. The replaced key is deleted once the overlap
+window has passed. Only the identity the lease was issued to can rotate it.
+Vault tells the engine a lease's ID when the lease is renewed, which binds
+the lease to its key, so leases must be renewed once before their first
+rotation.
+`
+
+func (b *Backend) pathCredentialsRotate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("name").(string)
+	leaseID := d.Get("lease_id").(string)
+	apiKeyId := d.Get("api_key").(string)
+	overlap := time.Duration(d.Get("overlap").(int)) * time.Second
+
+	if leaseID == "" || apiKeyId == "" {
+		return logical.ErrorResponse("lease_id and api_key are required"), nil
+	}
+
+	// Imported keys are leased through import/ instead of creds/.
+	if !strings.HasPrefix(leaseID, req.MountPoint+"creds/"+roleName+"/") && !strings.HasPrefix(leaseID, req.MountPoint+"import/"+roleName+"/") {
+		return logical.ErrorResponse("lease %q was not issued by role %q", leaseID, roleName), nil
+	}
+
+	if overlap < 0 || overlap > maxRotationOverlap {
+		return logical.ErrorResponse("overlap must be between 0 and %s", maxRotationOverlap), nil
+	}
+
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q does not exist", roleName), nil
+	}
+
+	if resp, err := b.denyRequester(req, roleName, roleEntry); resp != nil || err != nil {
+		return resp, err
+	}
+
+	b.rotationLock.Lock()
+	defer b.rotationLock.Unlock()
+
+	// Leases are found through the lease ID Vault bound to their key, never
+	// through the caller's word, so one lease cannot claim another's key.
+	hashedLeaseID := hashLeaseID(leaseID)
+	entry, err := req.Storage.Get(ctx, leaseIDStoragePrefix+hashedLeaseID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return logical.ErrorResponse("lease %q is not bound to an API key yet, renew it once before rotating it", leaseID), nil
+	}
+	originalKeyId := string(entry.Value)
+
+	key, err := getLeaseKey(ctx, req.Storage, originalKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil || key.Role != roleName {
+		return logical.ErrorResponse("lease %q was not issued by role %q, or was issued before rotation was supported", leaseID, roleName), nil
+	}
+
+	if !key.isOwner(req) {
+		return logical.ErrorResponse("lease %q was issued to a different identity", leaseID), logical.ErrPermissionDenied
+	}
+
+	if key.LeaseID != hashedLeaseID {
+		return logical.ErrorResponse("API key %q belongs to a different lease", apiKeyId), nil
+	}
+
+	if key.APIKey != apiKeyId {
+		return logical.ErrorResponse("API key %q is not the current key of lease %q", apiKeyId, leaseID), nil
+	}
+
+	// The new key keeps the owner the lease was issued with, even if the
+	// role's service account template would now resolve differently.
+	if isIdentityTemplate(roleEntry.ServiceAccount) {
+		if !roleEntry.allowsServiceAccount(key.Spec.Owner) {
+			return logical.ErrorResponse("service account %q is no longer allowed by role %q", key.Spec.Owner, roleName), nil
+		}
+		roleEntry.ServiceAccount = key.Spec.Owner
+	}
+
+	// Flink clients need the organization ID, which is looked up before the
+	// new key is created so a failed lookup leaves the lease untouched.
+	organizationID := ""
+	if roleEntry.credentialType() == credentialTypeFlink {
+		c, err := b.getClient(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if organizationID, err = getOrganizationID(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+
+	apiKey, err := b.createApiKey(ctx, req.Storage, roleEntry, key.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	deleteAfter := time.Now().Add(overlap)
+	err = setPendingDeletion(ctx, req.Storage, &pendingDeletion{
+		APIKey:      apiKeyId,
+		Role:        roleName,
+		DeleteAfter: deleteAfter,
+	})
+	if err == nil {
+		key.RetiredAPIKeys = append(key.RetiredAPIKeys, apiKeyId)
+		key.APIKey = apiKey.ApiKey
+		err = setLeaseKey(ctx, req.Storage, originalKeyId, key)
+	}
+	if err != nil {
+		if cleanupErr := b.deleteAPIKey(ctx, req.Storage, roleName, apiKey.ApiKey); cleanupErr != nil {
+			b.logError("error deleting API key after failed rotation",
+				append([]interface{}{"role", roleName, "api_key", apiKey.ApiKey}, errorLogArgs(cleanupErr)...)...)
+		}
+		return nil, fmt.Errorf("error recording rotated API key: %w", err)
+	}
+
+	b.logInfo("rotated API key of lease",
+		"role", roleName,
+		"api_key", apiKey.ApiKey,
+		"previous_api_key", apiKeyId,
+		"delete_previous_after", deleteAfter.Format(time.RFC3339))
+	b.credsRotated(roleName)
+	b.sendEvent(ctx, req, eventCredsRotate,
+		"role", roleName,
+		"api_key", apiKey.ApiKey,
+		"previous_api_key", apiKeyId,
+		"owner", key.Spec.Owner,
+		"resource", key.Spec.Resource)
+
+	data := map[string]interface{}{
+		"api_key":                apiKey.ApiKey,
+		"api_secret":             apiKey.ApiSecret,
+		"lease_id":               leaseID,
+		"previous_api_key":       apiKeyId,
+		"previous_key_delete_at": deleteAfter.UTC().Format(time.RFC3339),
+	}
+
+	for k, v := range roleEntry.connectionInfo(organizationID, apiKey) {
+		data[k] = v
+	}
+
+	return &logical.Response{Data: data}, nil
+}
+
+// bindLeaseID records the ID of the lease the key originalKeyId was issued
+// under, as passed by Vault on renewal, so only that lease can rotate it.
+func (b *Backend) bindLeaseID(ctx context.Context, s logical.Storage, originalKeyId, leaseID string) error {
+	if leaseID == "" {
+		return nil
+	}
+
+	b.rotationLock.Lock()
+	defer b.rotationLock.Unlock()
+
+	key, err := getLeaseKey(ctx, s, originalKeyId)
+	if err != nil {
+		return err
+	}
+
+	hashedLeaseID := hashLeaseID(leaseID)
+	if key == nil || key.LeaseID == hashedLeaseID {
+		return nil
+	}
+
+	if key.LeaseID != "" {
+		b.logWarn("API key is already bound to a different lease, keeping the existing binding",
+			"role", key.Role, "api_key", originalKeyId)
+		return nil
+	}
+
+	key.LeaseID = hashedLeaseID
+	if err := setLeaseKey(ctx, s, originalKeyId, key); err != nil {
+		return err
+	}
+
+	return s.Put(ctx, &logical.StorageEntry{
+		Key:   leaseIDStoragePrefix + hashedLeaseID,
+		Value: []byte(originalKeyId),
+	})
+}
+
+// revokeRotatedKeys deletes the keys that replaced, or were replaced by, the
+// key a lease was issued with, and forgets the lease's rotations.
+func (b *Backend) revokeRotatedKeys(ctx context.Context, s logical.Storage, role, originalKeyId string) error {
+	b.rotationLock.Lock()
+	defer b.rotationLock.Unlock()
+
+	key, err := getLeaseKey(ctx, s, originalKeyId)
+	if err != nil {
+		return err
+	}
+
+	if key == nil {
+		return nil
+	}
+
+	for _, id := range append(key.RetiredAPIKeys, key.APIKey) {
+		if id != originalKeyId {
+			if err := b.deleteAPIKey(ctx, s, role, id); err != nil {
+				return err
+			}
+		}
+		if err := s.Delete(ctx, pendingDeletionStoragePrefix+id); err != nil {
+			return err
+		}
+	}
+
+	if key.LeaseID != "" {
+		if err := s.Delete(ctx, leaseIDStoragePrefix+key.LeaseID); err != nil {
+			return err
+		}
+	}
+
+	return s.Delete(ctx, leaseKeyStoragePrefix+originalKeyId)
+}
+
+// currentLeaseKey returns the ID of the API key currently behind the lease
+// issued with originalKeyId.
+func currentLeaseKey(ctx context.Context, s logical.Storage, originalKeyId string) (string, error) {
+	key, err := getLeaseKey(ctx, s, originalKeyId)
+	if err != nil {
+		return "", err
+	}
+
+	if key == nil {
+		return originalKeyId, nil
+	}
+	return key.APIKey, nil
+}
+
+// deletePendingKeys deletes the keys replaced by rotations whose overlap
+// window has passed. Keys that cannot be deleted are retried on the next
+// run.
+func (b *Backend) deletePendingKeys(ctx context.Context, req *logical.Request) error {
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary | consts.ReplicationPerformanceStandby) {
+		return nil
+	}
+
+	ids, err := req.Storage.List(ctx, pendingDeletionStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, id := range ids {
+		entry, err := req.Storage.Get(ctx, pendingDeletionStoragePrefix+id)
+		if err != nil {
+			return err
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		pending := new(pendingDeletion)
+		if err := entry.DecodeJSON(pending); err != nil {
+			return err
+		}
+
+		if time.Now().Before(pending.DeleteAfter) {
+			continue
+		}
+
+		if err := b.deleteAPIKey(ctx, req.Storage, pending.Role, pending.APIKey); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := req.Storage.Delete(ctx, pendingDeletionStoragePrefix+id); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(errs...)
+}
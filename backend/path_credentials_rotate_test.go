@@ -0,0 +1,259 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const testLeaseID = "confluent/creds/orders/lease-1"
+
+func testCredentialsRotate(b logical.Backend, s logical.Storage, entityID string, data map[string]interface{}) (*logical.Response, error) {
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "creds/orders/rotate",
+		MountPoint: "confluent/",
+		EntityID:   entityID,
+		Data:       data,
+		Storage:    s,
+	})
+}
+
+// testLeaseRenew renews a lease the way Vault does, passing the lease ID.
+func testLeaseRenew(t *testing.T, b logical.Backend, s logical.Storage, secret *logical.Secret, leaseID string) *logical.Response {
+	t.Helper()
+	secret.LeaseID = leaseID
+	secret.IssueTime = time.Now()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Secret:    secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	return resp
+}
+
+func TestCredentialsRotate(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+	fake.addServiceAccount("sa-123", "orders")
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+		"ttl":             testTTL,
+		"max_ttl":         testMaxTTL,
+	})
+	require.NoError(t, err)
+
+	issued, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/orders",
+		EntityID:  "entity-a",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	original := issued.Data["api_key"].(string)
+
+	t.Run("Rotate before renewal - fail", func(t *testing.T) {
+		resp, err := testCredentialsRotate(b, s, "entity-a", map[string]interface{}{
+			"lease_id": testLeaseID,
+			"api_key":  original,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "renew it once")
+	})
+
+	testLeaseRenew(t, b, s, issued.Secret, testLeaseID)
+
+	t.Run("Rotate as another identity - fail", func(t *testing.T) {
+		resp, err := testCredentialsRotate(b, s, "entity-b", map[string]interface{}{
+			"lease_id": testLeaseID,
+			"api_key":  original,
+		})
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Rotate lease of another role - fail", func(t *testing.T) {
+		resp, err := testCredentialsRotate(b, s, "entity-a", map[string]interface{}{
+			"lease_id": "confluent/creds/payments/lease-2",
+			"api_key":  original,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Rotate another requester's lease - fail", func(t *testing.T) {
+		other, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/orders",
+			EntityID:  "entity-b",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		otherKey := other.Data["api_key"].(string)
+
+		// Before the other lease is bound, its ID cannot be claimed.
+		resp, err := testCredentialsRotate(b, s, "entity-a", map[string]interface{}{
+			"lease_id": "confluent/creds/orders/lease-2",
+			"api_key":  original,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		testLeaseRenew(t, b, s, other.Secret, "confluent/creds/orders/lease-2")
+
+		resp, err = testCredentialsRotate(b, s, "entity-a", map[string]interface{}{
+			"lease_id": "confluent/creds/orders/lease-2",
+			"api_key":  original,
+		})
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.True(t, resp.IsError())
+
+		resp, err = testCredentialsRotate(b, s, "entity-b", map[string]interface{}{
+			"lease_id": "confluent/creds/orders/lease-2",
+			"api_key":  otherKey,
+			"overlap":  0,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    other.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+	})
+
+	var rotated string
+	t.Run("Rotate", func(t *testing.T) {
+		resp, err := testCredentialsRotate(b, s, "entity-a", map[string]interface{}{
+			"lease_id": testLeaseID,
+			"api_key":  original,
+			"overlap":  0,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Nil(t, resp.Secret)
+
+		rotated = resp.Data["api_key"].(string)
+		require.NotEqual(t, original, rotated)
+		require.NotEmpty(t, resp.Data["api_secret"])
+		require.True(t, fake.hasToken(rotated))
+		require.True(t, fake.hasToken(original))
+
+		spec, err := fake.getTokenSpec(context.Background(), rotated)
+		require.NoError(t, err)
+		require.Equal(t, "sa-123", spec.Owner)
+	})
+
+	t.Run("Rotate replaced key - fail", func(t *testing.T) {
+		resp, err := testCredentialsRotate(b, s, "entity-a", map[string]interface{}{
+			"lease_id": testLeaseID,
+			"api_key":  original,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Delete replaced key after overlap", func(t *testing.T) {
+		require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+		require.False(t, fake.hasToken(original))
+		require.True(t, fake.hasToken(rotated))
+
+		pending, err := s.List(context.Background(), pendingDeletionStoragePrefix)
+		require.NoError(t, err)
+		require.Empty(t, pending)
+	})
+
+	t.Run("Renew rotated lease", func(t *testing.T) {
+		testLeaseRenew(t, b, s, issued.Secret, testLeaseID)
+	})
+
+	t.Run("Revoke rotated lease", func(t *testing.T) {
+		resp, err := testCredentialsRotate(b, s, "entity-a", map[string]interface{}{
+			"lease_id": testLeaseID,
+			"api_key":  rotated,
+			"overlap":  3600,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		latest := resp.Data["api_key"].(string)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    issued.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, fake.hasToken(rotated))
+		require.False(t, fake.hasToken(latest))
+
+		for _, prefix := range []string{leaseKeyStoragePrefix, leaseIDStoragePrefix, pendingDeletionStoragePrefix} {
+			entries, err := s.List(context.Background(), prefix)
+			require.NoError(t, err)
+			require.Empty(t, entries, prefix)
+		}
+	})
+}
+
+func TestCredentialsRotateFlink(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+	sink := useInmemMetrics(t)
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"credential_type": credentialTypeFlink,
+		"service_account": "sa-123",
+		"environment":     "env-123",
+		"cloud":           "aws",
+		"region":          "us-east-1",
+		"skip_validation": true,
+	})
+	require.NoError(t, err)
+
+	issued, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/orders",
+		EntityID:  "entity-a",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	original := issued.Data["api_key"].(string)
+	testLeaseRenew(t, b, s, issued.Secret, testLeaseID)
+
+	t.Run("Rotate with failed organization lookup - fail", func(t *testing.T) {
+		fake.organizationID = ""
+		defer func() { fake.organizationID = "org-fake" }()
+
+		_, err := testCredentialsRotate(b, s, "entity-a", map[string]interface{}{
+			"lease_id": testLeaseID,
+			"api_key":  original,
+		})
+		require.Error(t, err)
+
+		ids, err := fake.listTokens(context.Background(), "sa-123")
+		require.NoError(t, err)
+		require.Equal(t, []string{original}, ids)
+
+		pending, err := s.List(context.Background(), pendingDeletionStoragePrefix)
+		require.NoError(t, err)
+		require.Empty(t, pending)
+	})
+
+	t.Run("Rotate", func(t *testing.T) {
+		resp, err := testCredentialsRotate(b, s, "entity-a", map[string]interface{}{
+			"lease_id": testLeaseID,
+			"api_key":  original,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, "org-fake", resp.Data["properties"].(map[string]string)["client.organization-id"])
+		require.Equal(t, 1, counterValue(sink, metricKey(metricCredsRotated, "role", "orders")))
+	})
+}
@@ -0,0 +1,242 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCredentials(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+	fake.addServiceAccount("sa-123", "orders")
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+		"ttl":             testTTL,
+		"max_ttl":         testMaxTTL,
+	})
+	require.NoError(t, err)
+
+	t.Run("Issue and Revoke", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		apiKey := resp.Data["api_key"].(string)
+		require.True(t, fake.hasToken(apiKey))
+		require.Equal(t, "orders", resp.Secret.InternalData["role"])
+		require.Equal(t, time.Duration(testTTL)*time.Second, resp.Secret.TTL)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, fake.hasToken(apiKey))
+	})
+
+	t.Run("Issue while rate limited - fail", func(t *testing.T) {
+		fake.failNext("createToken", http.StatusTooManyRequests)
+
+		_, err := testCredentialsRead(b, s, "orders")
+		require.Error(t, err)
+		require.Equal(t, 2, fake.callCount("createToken"))
+	})
+
+	t.Run("Issue with latency past deadline - fail", func(t *testing.T) {
+		fake.latency = time.Second
+		defer func() { fake.latency = 0 }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/orders",
+			Storage:   s,
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Import before key is visible - fail", func(t *testing.T) {
+		fake.visibilityDelay = time.Hour
+		defer func() { fake.visibilityDelay = 0 }()
+
+		key, err := fake.createToken(context.Background(), apiKeySpec{Owner: "sa-123"})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "import/orders",
+			Data:      map[string]interface{}{"api_key": key.ApiKey, "api_secret": key.ApiSecret},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		fake.visibilityDelay = 0
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "import/orders",
+			Data:      map[string]interface{}{"api_key": key.ApiKey, "api_secret": key.ApiSecret},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, key.ApiKey, resp.Secret.InternalData["api_key"])
+	})
+
+	t.Run("Revoke on server error - fail", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+
+		fake.failNext("deleteToken", http.StatusServiceUnavailable)
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "temporary error")
+		require.True(t, fake.hasToken(resp.Data["api_key"].(string)))
+	})
+
+	t.Run("Revoke key deleted in Confluent", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+
+		require.NoError(t, fake.deleteToken(context.Background(), resp.Data["api_key"].(string)))
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Revoke with rejected credentials - fail", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+
+		fake.failNext("deleteToken", http.StatusUnauthorized)
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "credentials in config")
+	})
+
+	t.Run("Revoke without key ID - fail", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+
+		delete(resp.Secret.InternalData, "api_key")
+		calls := fake.callCount("deleteToken")
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.Error(t, err)
+		require.Equal(t, calls, fake.callCount("deleteToken"))
+	})
+}
+
+func TestCredentialsRenew(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+	fake.addServiceAccount("sa-123", "orders")
+	fake.addServiceAccount("sa-456", "payments")
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+		"ttl":             testTTL,
+		"max_ttl":         testMaxTTL,
+	})
+	require.NoError(t, err)
+
+	renew := func(secret *logical.Secret) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Secret:    secret,
+			Storage:   s,
+		})
+	}
+
+	t.Run("Renew", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+
+		resp.Secret.IssueTime = time.Now()
+		renewed, err := renew(resp.Secret)
+		require.NoError(t, err)
+		require.False(t, renewed.IsError())
+		require.Equal(t, time.Duration(testTTL)*time.Second, renewed.Secret.TTL)
+		require.Equal(t, 1, fake.callCount("getTokenSpec"))
+	})
+
+	t.Run("Renew capped at max_ttl", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+
+		resp.Secret.IssueTime = time.Now().Add(-time.Duration(testMaxTTL-60) * time.Second)
+		renewed, err := renew(resp.Secret)
+		require.NoError(t, err)
+		require.LessOrEqual(t, renewed.Secret.TTL, 60*time.Second)
+		require.NotEmpty(t, renewed.Warnings)
+
+		resp.Secret.IssueTime = time.Now().Add(-time.Duration(testMaxTTL+60) * time.Second)
+		_, err = renew(resp.Secret)
+		require.Error(t, err)
+	})
+
+	t.Run("Renew deleted key - fail", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+
+		require.NoError(t, fake.deleteToken(context.Background(), resp.Data["api_key"].(string)))
+
+		resp.Secret.IssueTime = time.Now()
+		renewed, err := renew(resp.Secret)
+		require.NoError(t, err)
+		require.True(t, renewed.IsError())
+		require.Contains(t, renewed.Error().Error(), "no longer exists")
+	})
+
+	t.Run("Renew after role drift", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+		resp.Secret.IssueTime = time.Now()
+
+		_, err = testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"service_account": "sa-456",
+		})
+		require.NoError(t, err)
+
+		renewed, err := renew(resp.Secret)
+		require.NoError(t, err)
+		require.True(t, renewed.IsError())
+		require.Contains(t, renewed.Error().Error(), "service account changed")
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/orders",
+			Data:      map[string]interface{}{"allow_renewal_on_drift": true},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		renewed, err = renew(resp.Secret)
+		require.NoError(t, err)
+		require.False(t, renewed.IsError())
+	})
+}
@@ -0,0 +1,203 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"time"
+)
+
+func pathImport(b *Backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "import/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role the API key belongs to",
+				Required:    true,
+			},
+			"api_key": {
+				Type:        framework.TypeString,
+				Description: "ID of the existing Confluent API key",
+				Required:    true,
+			},
+			"api_secret": {
+				Type:        framework.TypeString,
+				Description: "Secret of the existing Confluent API key",
+				Required:    true,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "API Secret",
+					Sensitive: true,
+				},
+			},
+			"static": {
+				Type:        framework.TypeBool,
+				Description: "Register the key as a static credential under static-creds/ instead of returning it as a leased secret.",
+			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Lease for the imported key. Defaults to the role's ttl and is capped at its max_ttl. Ignored for static credentials.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathImportWrite,
+			},
+		},
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+const pathImportHelpSyn = `
+Import an existing Confluent API Key so Vault manages its lifecycle.
+`
+
+const pathImportHelpDesc = `
+This path takes ownership of an API Key created outside of Vault. The key
+must have the same owner and resource as the role. The secrets of Cloud API
+keys are verified with Confluent. Keys scoped to a resource, such as Kafka,
+Schema Registry or Flink keys, cannot authenticate to the Cloud API, so
+their secrets are not verified and the response warns about it. Imported
+keys are either returned as a leased secret that can be rotated like issued
+ones, or registered as a static credential that is deleted from Confluent
+when it is removed from Vault. A key can only be imported once.
+`
+
+func (b *Backend) pathImportWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("name").(string)
+
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q does not exist", roleName), nil
+	}
+
+	if !roleEntry.requiresServiceAccount() {
+		return logical.ErrorResponse("role %q issues %s credentials, only API keys can be imported", roleName, roleEntry.credentialType()), nil
+	}
+
+	apiKeyId := d.Get("api_key").(string)
+	apiSecret := d.Get("api_secret").(string)
+	if apiKeyId == "" || apiSecret == "" {
+		return logical.ErrorResponse("both api_key and api_secret must be provided"), nil
+	}
+
+	if resp, err := checkNotImported(ctx, req.Storage, apiKeyId); resp != nil || err != nil {
+		return resp, err
+	}
+
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := c.getTokenSpec(ctx, apiKeyId)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	expected := roleEntry.keySpec()
+	if !roleEntry.allowsServiceAccount(spec.Owner) {
+		return logical.ErrorResponse("API key %q is owned by %q, not the role's service account %q", apiKeyId, spec.Owner, expected.Owner), nil
+	}
+	if spec.Resource != expected.Resource {
+		return logical.ErrorResponse("API key %q is scoped to resource %q, not the role's resource %q", apiKeyId, spec.Resource, expected.Resource), nil
+	}
+
+	// Only Cloud API keys can authenticate to the Cloud API, so the secrets
+	// of keys scoped to a resource are taken as given.
+	var warning string
+	if spec.Resource == "" {
+		if err := c.verifyTokenSecret(ctx, apiKeyId, apiSecret); err != nil {
+			if isRetryable(err) {
+				return nil, fmt.Errorf("temporary error verifying API key %q, retry the import: %w", apiKeyId, err)
+			}
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	} else {
+		warning = fmt.Sprintf("the secret of API key %q is scoped to resource %q and was not verified with Confluent", apiKeyId, spec.Resource)
+	}
+
+	// Flink clients need the organization ID, which is looked up before the
+	// key is recorded.
+	organizationID := ""
+	if !d.Get("static").(bool) && roleEntry.credentialType() == credentialTypeFlink {
+		if organizationID, err = getOrganizationID(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.Get("static").(bool) {
+		cred := &staticCredential{
+			ApiKey:     apiKeyId,
+			ApiSecret:  apiSecret,
+			Role:       roleName,
+			Spec:       *spec,
+			ImportedAt: time.Now().UTC(),
+		}
+		if err := setStaticCredential(ctx, req.Storage, cred); err != nil {
+			return nil, err
+		}
+
+		if warning == "" {
+			return nil, nil
+		}
+		resp := &logical.Response{}
+		resp.AddWarning(warning)
+		return resp, nil
+	}
+
+	ttl := roleEntry.TTL
+	if ttlRaw, ok := d.GetOk("ttl"); ok {
+		ttl = time.Duration(ttlRaw.(int)) * time.Second
+	}
+
+	if roleEntry.MaxTTL > 0 && ttl > roleEntry.MaxTTL {
+		ttl = roleEntry.MaxTTL
+	}
+
+	apiKey := &confluentApiKey{ApiKey: apiKeyId, ApiSecret: apiSecret}
+	data := map[string]interface{}{
+		"api_key":    apiKeyId,
+		"api_secret": apiSecret,
+	}
+	for k, v := range roleEntry.connectionInfo(organizationID, apiKey) {
+		data[k] = v
+	}
+
+	resp, err := b.leaseAPIKey(ctx, req, roleName, *spec, apiKey, data, ttl, roleEntry.MaxTTL)
+	if err != nil {
+		return nil, err
+	}
+	if warning != "" {
+		resp.AddWarning(warning)
+	}
+	return resp, nil
+}
+
+// checkNotImported rejects keys that Vault already manages, either behind a
+// lease or as a static credential, since each would delete the key when it
+// is revoked.
+func checkNotImported(ctx context.Context, s logical.Storage, apiKeyId string) (*logical.Response, error) {
+	leased, err := getLeaseKey(ctx, s, apiKeyId)
+	if err != nil {
+		return nil, err
+	}
+	if leased != nil {
+		return logical.ErrorResponse("API key %q is already leased by role %q", apiKeyId, leased.Role), nil
+	}
+
+	existing, err := getStaticCredential(ctx, s, apiKeyId)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return logical.ErrorResponse("API key %q is already registered as a static credential", apiKeyId), nil
+	}
+	return nil, nil
+}
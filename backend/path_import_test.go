@@ -0,0 +1,218 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestImport(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+	sink := useInmemMetrics(t)
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+		"ttl":             testTTL,
+		"max_ttl":         testMaxTTL,
+	})
+	require.NoError(t, err)
+
+	_, err = testTokenRoleCreate(t, b, s, "kafka", map[string]interface{}{
+		"service_account": "sa-123",
+		"resource":        "lkc-123",
+	})
+	require.NoError(t, err)
+
+	_, err = testTokenRoleCreate(t, b, s, "breakglass", map[string]interface{}{
+		"credential_type":  "user",
+		"allowed_user_ids": "u-123",
+	})
+	require.NoError(t, err)
+
+	importKey := func(role string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "import/" + role,
+			MountPoint: "confluent/",
+			EntityID:   "entity-a",
+			Data:       data,
+			Storage:    s,
+		})
+	}
+
+	newKey := func(spec apiKeySpec) *confluentApiKey {
+		key, err := fake.createToken(context.Background(), spec)
+		require.NoError(t, err)
+		return key
+	}
+
+	t.Run("Import into non API key Role - fail", func(t *testing.T) {
+		key := newKey(apiKeySpec{Owner: "u-123", OwnerKind: userOwnerKind})
+		resp, err := importKey("breakglass", map[string]interface{}{
+			"api_key":    key.ApiKey,
+			"api_secret": key.ApiSecret,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "only API keys can be imported")
+	})
+
+	t.Run("Import resource scoped Key", func(t *testing.T) {
+		other := newKey(apiKeySpec{Owner: "sa-123", Resource: "lkc-456"})
+		resp, err := importKey("kafka", map[string]interface{}{
+			"api_key":    other.ApiKey,
+			"api_secret": other.ApiSecret,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "not the role's resource")
+
+		key := newKey(apiKeySpec{Owner: "sa-123", Resource: "lkc-123"})
+		resp, err = importKey("kafka", map[string]interface{}{
+			"api_key":    key.ApiKey,
+			"api_secret": key.ApiSecret,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.NotNil(t, resp.Secret)
+		require.Equal(t, "lkc-123", resp.Secret.InternalData["resource"])
+		require.Len(t, resp.Warnings, 1)
+		require.Contains(t, resp.Warnings[0], "not verified")
+		require.Equal(t, 0, fake.callCount("verifyTokenSecret"))
+	})
+
+	t.Run("Import with wrong secret - fail", func(t *testing.T) {
+		key := newKey(apiKeySpec{Owner: "sa-123"})
+		resp, err := importKey("orders", map[string]interface{}{
+			"api_key":    key.ApiKey,
+			"api_secret": "wrong",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "rejected")
+	})
+
+	key := newKey(apiKeySpec{Owner: "sa-123"})
+
+	t.Run("Import while Confluent is unavailable - fail", func(t *testing.T) {
+		for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+			for _, static := range []bool{false, true} {
+				fake.failNext("verifyTokenSecret", status)
+				_, err := importKey("orders", map[string]interface{}{
+					"api_key":    key.ApiKey,
+					"api_secret": key.ApiSecret,
+					"static":     static,
+				})
+				require.ErrorContains(t, err, "temporary error")
+
+				leased, err := getLeaseKey(context.Background(), s, key.ApiKey)
+				require.NoError(t, err)
+				require.Nil(t, leased)
+
+				cred, err := getStaticCredential(context.Background(), s, key.ApiKey)
+				require.NoError(t, err)
+				require.Nil(t, cred)
+			}
+		}
+	})
+
+	var leased *logical.Response
+	t.Run("Import leased Key", func(t *testing.T) {
+		resp, err := importKey("orders", map[string]interface{}{
+			"api_key":    key.ApiKey,
+			"api_secret": key.ApiSecret,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.NotNil(t, resp.Secret)
+		require.Equal(t, time.Duration(testTTL)*time.Second, resp.Secret.TTL)
+		require.Equal(t, "sa-123", resp.Secret.InternalData["owner"])
+		leased = resp
+
+		record, err := getLeaseKey(context.Background(), s, key.ApiKey)
+		require.NoError(t, err)
+		require.Equal(t, "orders", record.Role)
+		require.Equal(t, "entity-a", record.EntityID)
+
+		require.Equal(t, 1, counterValue(sink, metricKey(metricCredsIssued, "role", "orders")))
+	})
+
+	t.Run("Import Key again - fail", func(t *testing.T) {
+		for _, static := range []bool{false, true} {
+			resp, err := importKey("orders", map[string]interface{}{
+				"api_key":    key.ApiKey,
+				"api_secret": key.ApiSecret,
+				"static":     static,
+			})
+			require.NoError(t, err)
+			require.True(t, resp.IsError())
+			require.Contains(t, resp.Error().Error(), "already leased")
+		}
+	})
+
+	t.Run("Rotate imported Key", func(t *testing.T) {
+		testLeaseRenew(t, b, s, leased.Secret, "confluent/import/orders/lease-1")
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "creds/orders/rotate",
+			MountPoint: "confluent/",
+			EntityID:   "entity-a",
+			Data: map[string]interface{}{
+				"lease_id": "confluent/import/orders/lease-1",
+				"api_key":  key.ApiKey,
+			},
+			Storage: s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.NotEqual(t, key.ApiKey, resp.Data["api_key"])
+	})
+
+	t.Run("Import static Key", func(t *testing.T) {
+		static := newKey(apiKeySpec{Owner: "sa-123"})
+		resp, err := importKey("orders", map[string]interface{}{
+			"api_key":    static.ApiKey,
+			"api_secret": static.ApiSecret,
+			"static":     true,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = importKey("orders", map[string]interface{}{
+			"api_key":    static.ApiKey,
+			"api_secret": static.ApiSecret,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "static credential")
+	})
+
+	t.Run("Delete static Key already deleted in Confluent", func(t *testing.T) {
+		static := newKey(apiKeySpec{Owner: "sa-123"})
+		resp, err := importKey("orders", map[string]interface{}{
+			"api_key":    static.ApiKey,
+			"api_secret": static.ApiSecret,
+			"static":     true,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		require.NoError(t, fake.deleteToken(context.Background(), static.ApiKey))
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "static-creds/" + static.ApiKey,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		cred, err := getStaticCredential(context.Background(), s, static.ApiKey)
+		require.NoError(t, err)
+		require.Nil(t, cred)
+	})
+}
@@ -0,0 +1,630 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"sort"
+	"time"
+)
+
+const (
+	libraryStoragePrefix = "library/"
+)
+
+type librarySet struct {
+	TTL                       time.Duration          `json:"ttl"`
+	MaxTTL                    time.Duration          `json:"max_ttl"`
+	DisableCheckInEnforcement bool                   `json:"disable_check_in_enforcement"`
+	RotateOnCheckIn           bool                   `json:"rotate_on_check_in"`
+	Keys                      map[string]*libraryKey `json:"keys"`
+}
+
+type libraryKey struct {
+	ApiKey    string           `json:"api_key"`
+	ApiSecret string           `json:"api_secret"`
+	Spec      apiKeySpec       `json:"spec"`
+	CheckOut  *libraryCheckOut `json:"check_out,omitempty"`
+
+	// Unverified is set for keys adopted with verify=false, whose secrets
+	// were not checked with Confluent.
+	Unverified bool `json:"unverified,omitempty"`
+}
+
+type libraryCheckOut struct {
+	ID               string    `json:"id"`
+	BorrowerEntityID string    `json:"borrower_entity_id"`
+	BorrowerAccessor string    `json:"borrower_accessor"`
+	CheckedOutAt     time.Time `json:"checked_out_at"`
+}
+
+func (s *librarySet) toResponseData() map[string]interface{} {
+	apiKeys := make([]string, 0, len(s.Keys))
+	for id := range s.Keys {
+		apiKeys = append(apiKeys, id)
+	}
+	sort.Strings(apiKeys)
+
+	return map[string]interface{}{
+		"ttl":                          s.TTL.Seconds(),
+		"max_ttl":                      s.MaxTTL.Seconds(),
+		"disable_check_in_enforcement": s.DisableCheckInEnforcement,
+		"rotate_on_check_in":           s.RotateOnCheckIn,
+		"api_keys":                     apiKeys,
+	}
+}
+
+// isBorrower reports whether the request was made by the same identity that
+// checked out the key.
+func (c *libraryCheckOut) isBorrower(req *logical.Request) bool {
+	if c.BorrowerEntityID != "" {
+		return c.BorrowerEntityID == req.EntityID
+	}
+	return c.BorrowerAccessor != "" && c.BorrowerAccessor == req.ClientTokenAccessor
+}
+
+func pathLibrary(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "library/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the library set",
+					Required:    true,
+				},
+				"api_keys": {
+					Type:        framework.TypeKVPairs,
+					Description: "Existing Confluent API keys to adopt, as a map of API key ID to API secret",
+				},
+				"verify": {
+					Type:        framework.TypeBool,
+					Default:     true,
+					Description: "Verify the secrets of adopted keys with Confluent. Keys scoped to a resource cannot be verified, and can only be adopted with verify=false, which records them as unverified.",
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Default lease for checked out keys. If not set or set to 0, will use system default.",
+				},
+				"max_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Maximum time a key can be checked out. If not set or set to 0, will use system default.",
+				},
+				"disable_check_in_enforcement": {
+					Type:        framework.TypeBool,
+					Description: "Allow any caller, not only the borrower, to check a key back in.",
+				},
+				"rotate_on_check_in": {
+					Type:        framework.TypeBool,
+					Description: "Replace a key with a new one for the same owner and resource when it is checked in.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathLibraryRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathLibraryWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathLibraryWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathLibraryDelete,
+				},
+			},
+			ExistenceCheck:  b.pathLibraryExistenceCheck,
+			HelpSynopsis:    pathLibraryHelpSynopsis,
+			HelpDescription: pathLibraryHelpDescription,
+		},
+		{
+			Pattern: "library/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathLibraryList,
+				},
+			},
+			HelpSynopsis:    pathLibraryListHelpSynopsis,
+			HelpDescription: pathLibraryListHelpDescription,
+		},
+		{
+			Pattern: "library/" + framework.GenericNameRegex("name") + "/check-out$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the library set",
+					Required:    true,
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Requested lease for the checked out key. Capped at the set's ttl.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathLibraryCheckOut,
+				},
+			},
+			HelpSynopsis:    pathLibraryCheckOutHelpSynopsis,
+			HelpDescription: pathLibraryCheckOutHelpDescription,
+		},
+		{
+			Pattern: "library/" + framework.GenericNameRegex("name") + "/check-in$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the library set",
+					Required:    true,
+				},
+				"api_keys": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "API key IDs to check in. May be omitted if the caller has exactly one key checked out.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathLibraryCheckIn,
+				},
+			},
+			HelpSynopsis:    pathLibraryCheckInHelpSynopsis,
+			HelpDescription: pathLibraryCheckInHelpDescription,
+		},
+		{
+			Pattern: "library/" + framework.GenericNameRegex("name") + "/status$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the library set",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathLibraryStatus,
+				},
+			},
+			HelpSynopsis:    pathLibraryStatusHelpSynopsis,
+			HelpDescription: pathLibraryStatusHelpDescription,
+		},
+	}
+}
+
+const (
+	pathLibraryHelpSynopsis    = `Manages sets of pre-existing Confluent API keys that can be checked out.`
+	pathLibraryHelpDescription = `
+This path adopts existing Confluent API keys into a library set. Keys are
+looked up in Confluent when added, and their secrets are stored seal-wrapped.
+Secrets of Cloud API keys are verified with Confluent. Keys scoped to a
+resource, such as partner Kafka keys, cannot be verified and are adopted with
+verify=false, which the set's status reports. Keys in a set can be
+exclusively checked out and checked back in.
+`
+	pathLibraryListHelpSynopsis        = `List the existing library sets in Confluent backend`
+	pathLibraryListHelpDescription     = `Library sets will be listed by name.`
+	pathLibraryCheckOutHelpSynopsis    = `Check out an available API key from a library set.`
+	pathLibraryCheckOutHelpDescription = `
+Checks out an available API key from the set. The key is held exclusively by
+the caller until it is checked in or its lease expires.
+`
+	pathLibraryCheckInHelpSynopsis    = `Check API keys back into a library set.`
+	pathLibraryCheckInHelpDescription = `
+Checks API keys back into the set. Unless check-in enforcement is disabled,
+only the identity that checked out a key may check it in. If the set rotates
+on check-in, the key is replaced with a new one for the same owner.
+`
+	pathLibraryStatusHelpSynopsis    = `Show which API keys in a library set are available.`
+	pathLibraryStatusHelpDescription = `Lists each API key in the set and whether it is checked out.`
+)
+
+func (b *Backend) pathLibraryExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, req.Path)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func getLibrarySet(ctx context.Context, s logical.Storage, name string) (*librarySet, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing library set name")
+	}
+
+	entry, err := s.Get(ctx, libraryStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	set := new(librarySet)
+	if err := entry.DecodeJSON(set); err != nil {
+		return nil, err
+	}
+
+	if set.Keys == nil {
+		set.Keys = make(map[string]*libraryKey)
+	}
+	return set, nil
+}
+
+func setLibrarySet(ctx context.Context, s logical.Storage, name string, set *librarySet) error {
+	entry, err := logical.StorageEntryJSON(libraryStoragePrefix+name, set)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		return fmt.Errorf("failed to create storage entry for library set")
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func (b *Backend) pathLibraryRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	set, err := getLibrarySet(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: set.toResponseData(),
+	}, nil
+}
+
+func (b *Backend) pathLibraryWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	createOperation := req.Operation == logical.CreateOperation
+
+	if set == nil {
+		if !createOperation {
+			return nil, errors.New("library set not found during update operation")
+		}
+		set = &librarySet{Keys: make(map[string]*libraryKey)}
+	}
+
+	if apiKeysRaw, ok := d.GetOk("api_keys"); ok {
+		apiKeys := apiKeysRaw.(map[string]string)
+		if len(apiKeys) == 0 {
+			return logical.ErrorResponse("api_keys must contain at least one key"), nil
+		}
+
+		for id, key := range set.Keys {
+			if _, ok := apiKeys[id]; !ok && key.CheckOut != nil {
+				return logical.ErrorResponse("cannot remove API key %q while it is checked out", id), nil
+			}
+		}
+
+		// Secrets of new keys, and changed secrets of adopted ones, are
+		// verified with Confluent before they are stored, unless verify is
+		// unset. Only Cloud API keys can be verified.
+		verify := d.Get("verify").(bool)
+		var c cloudClient
+		keys := make(map[string]*libraryKey, len(apiKeys))
+		for id, secret := range apiKeys {
+			existing, ok := set.Keys[id]
+			if ok && existing.ApiSecret == secret {
+				keys[id] = existing
+				continue
+			}
+
+			if c == nil {
+				if c, err = b.getClient(ctx, req.Storage); err != nil {
+					return nil, err
+				}
+			}
+
+			if !ok {
+				spec, err := c.getTokenSpec(ctx, id)
+				if err != nil {
+					return logical.ErrorResponse("unable to adopt API key %q: %s", id, err), nil
+				}
+				existing = &libraryKey{ApiKey: id, Spec: *spec}
+			}
+
+			if verify {
+				if existing.Spec.Resource != "" {
+					return logical.ErrorResponse("unable to adopt API key %q: keys scoped to resource %q cannot be verified, set verify=false to adopt it unverified", id, existing.Spec.Resource), nil
+				}
+
+				if err := c.verifyTokenSecret(ctx, id, secret); err != nil {
+					if isRetryable(err) {
+						return nil, fmt.Errorf("temporary error verifying API key %q, retry adopting it: %w", id, err)
+					}
+					return logical.ErrorResponse("unable to adopt API key %q: %s", id, err), nil
+				}
+			}
+
+			existing.ApiSecret = secret
+			existing.Unverified = !verify
+			keys[id] = existing
+		}
+		set.Keys = keys
+	} else if createOperation {
+		return logical.ErrorResponse("missing api_keys in library set"), nil
+	}
+
+	if ttlRaw, ok := d.GetOk("ttl"); ok {
+		set.TTL = time.Duration(ttlRaw.(int)) * time.Second
+	}
+
+	if maxTTLRaw, ok := d.GetOk("max_ttl"); ok {
+		set.MaxTTL = time.Duration(maxTTLRaw.(int)) * time.Second
+	}
+
+	if set.MaxTTL != 0 && set.TTL > set.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+
+	if disable, ok := d.GetOk("disable_check_in_enforcement"); ok {
+		set.DisableCheckInEnforcement = disable.(bool)
+	}
+
+	if rotate, ok := d.GetOk("rotate_on_check_in"); ok {
+		set.RotateOnCheckIn = rotate.(bool)
+	}
+
+	if err := setLibrarySet(ctx, req.Storage, name, set); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathLibraryDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return nil, nil
+	}
+
+	for id, key := range set.Keys {
+		if key.CheckOut != nil {
+			return logical.ErrorResponse("cannot delete library set while API key %q is checked out", id), nil
+		}
+	}
+
+	if err := req.Storage.Delete(ctx, libraryStoragePrefix+name); err != nil {
+		return nil, fmt.Errorf("error deleting library set: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathLibraryList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, libraryStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *Backend) pathLibraryStatus(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	set, err := getLibrarySet(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return nil, nil
+	}
+
+	status := make(map[string]interface{}, len(set.Keys))
+	for id, key := range set.Keys {
+		keyStatus := map[string]interface{}{
+			"available": key.CheckOut == nil,
+			"verified":  !key.Unverified,
+		}
+		if key.CheckOut != nil {
+			keyStatus["borrower_entity_id"] = key.CheckOut.BorrowerEntityID
+			keyStatus["borrower_accessor"] = key.CheckOut.BorrowerAccessor
+			keyStatus["checked_out_at"] = key.CheckOut.CheckedOutAt.Format(time.RFC3339)
+		}
+		status[id] = keyStatus
+	}
+
+	return &logical.Response{
+		Data: status,
+	}, nil
+}
+
+func (b *Backend) pathLibraryCheckOut(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return logical.ErrorResponse("library set %q does not exist", name), nil
+	}
+
+	ids := make([]string, 0, len(set.Keys))
+	for id, key := range set.Keys {
+		if key.CheckOut == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return logical.ErrorResponse("no API keys are available in library set %q", name), nil
+	}
+	sort.Strings(ids)
+
+	checkOutID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	key := set.Keys[ids[0]]
+	key.CheckOut = &libraryCheckOut{
+		ID:               checkOutID,
+		BorrowerEntityID: req.EntityID,
+		BorrowerAccessor: req.ClientTokenAccessor,
+		CheckedOutAt:     time.Now().UTC(),
+	}
+
+	if err := setLibrarySet(ctx, req.Storage, name, set); err != nil {
+		return nil, err
+	}
+
+	resp := b.Secret(ConfluentLibraryKeyType).Response(map[string]interface{}{
+		"api_key":    key.ApiKey,
+		"api_secret": key.ApiSecret,
+	}, map[string]interface{}{
+		"set":          name,
+		"api_key":      key.ApiKey,
+		"check_out_id": checkOutID,
+	})
+
+	ttl := set.TTL
+	if ttlRaw, ok := d.GetOk("ttl"); ok {
+		requested := time.Duration(ttlRaw.(int)) * time.Second
+		if ttl == 0 || requested < ttl {
+			ttl = requested
+		}
+	}
+
+	if ttl > 0 {
+		resp.Secret.TTL = ttl
+	}
+
+	if set.MaxTTL > 0 {
+		resp.Secret.MaxTTL = set.MaxTTL
+	}
+
+	return resp, nil
+}
+
+func (b *Backend) pathLibraryCheckIn(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return logical.ErrorResponse("library set %q does not exist", name), nil
+	}
+
+	ids := d.Get("api_keys").([]string)
+	if len(ids) == 0 {
+		for id, key := range set.Keys {
+			if key.CheckOut != nil && key.CheckOut.isBorrower(req) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) != 1 {
+			return logical.ErrorResponse("api_keys must be provided when the caller does not have exactly one key checked out"), nil
+		}
+	}
+
+	for _, id := range ids {
+		key, ok := set.Keys[id]
+		if !ok {
+			return logical.ErrorResponse("API key %q is not part of library set %q", id, name), nil
+		}
+		if key.CheckOut != nil && !set.DisableCheckInEnforcement && !key.CheckOut.isBorrower(req) {
+			return logical.ErrorResponse("API key %q is checked out by another identity", id), logical.ErrPermissionDenied
+		}
+	}
+
+	checkIns := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if set.Keys[id].CheckOut == nil {
+			continue
+		}
+
+		newID, err := b.checkInLibraryKey(ctx, req.Storage, set, id)
+		if err != nil {
+			if err := setLibrarySet(ctx, req.Storage, name, set); err != nil {
+				return nil, err
+			}
+			return nil, err
+		}
+		checkIns = append(checkIns, newID)
+	}
+
+	if err := setLibrarySet(ctx, req.Storage, name, set); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"check_ins": checkIns,
+		},
+	}, nil
+}
+
+// checkInLibraryKey releases a key in the set, rotating it first if the set
+// is configured to do so. It returns the ID of the key now held by the set.
+// The caller is responsible for persisting the set.
+func (b *Backend) checkInLibraryKey(ctx context.Context, s logical.Storage, set *librarySet, id string) (string, error) {
+	key := set.Keys[id]
+
+	if !set.RotateOnCheckIn {
+		key.CheckOut = nil
+		return id, nil
+	}
+
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	replacement, err := c.createToken(ctx, key.Spec)
+	if err != nil {
+		return "", fmt.Errorf("error rotating API key %q: %w", id, err)
+	}
+
+	if err := c.deleteToken(ctx, id); err != nil {
+		if cleanupErr := c.deleteToken(ctx, replacement.ApiKey); cleanupErr != nil {
+			return "", fmt.Errorf("error rotating API key %q: %w (replacement %q was also not removed: %s)", id, err, replacement.ApiKey, cleanupErr)
+		}
+		return "", fmt.Errorf("error rotating API key %q: %w", id, err)
+	}
+
+	delete(set.Keys, id)
+	set.Keys[replacement.ApiKey] = &libraryKey{
+		ApiKey:    replacement.ApiKey,
+		ApiSecret: replacement.ApiSecret,
+		Spec:      key.Spec,
+	}
+
+	return replacement.ApiKey, nil
+}
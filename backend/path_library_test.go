@@ -0,0 +1,249 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	librarySetName = "partners"
+)
+
+func TestLibrarySet(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	// Seed the set directly, since adopting keys through the API requires a
+	// reachable Confluent organization.
+	err := setLibrarySet(context.Background(), s, librarySetName, &librarySet{
+		TTL:    time.Duration(testTTL) * time.Second,
+		MaxTTL: time.Duration(testMaxTTL) * time.Second,
+		Keys: map[string]*libraryKey{
+			"KEY1": {ApiKey: "KEY1", ApiSecret: "secret1", Spec: apiKeySpec{Owner: "sa-123"}},
+			"KEY2": {ApiKey: "KEY2", ApiSecret: "secret2", Spec: apiKeySpec{Owner: "sa-123"}},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("List Library Sets", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "library/",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{librarySetName}, resp.Data["keys"])
+	})
+
+	t.Run("Create Library Set without keys - fail", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "library/empty",
+			Data:      map[string]interface{}{"ttl": testTTL},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	var first *logical.Response
+	t.Run("Check Out", func(t *testing.T) {
+		first, err = testLibraryCheckOut(t, b, s, "entity-a")
+		require.NoError(t, err)
+		require.Equal(t, "KEY1", first.Data["api_key"])
+		require.Equal(t, "secret1", first.Data["api_secret"])
+		require.Equal(t, time.Duration(testTTL)*time.Second, first.Secret.TTL)
+
+		resp, err := testLibraryCheckOut(t, b, s, "entity-b")
+		require.NoError(t, err)
+		require.Equal(t, "KEY2", resp.Data["api_key"])
+
+		resp, err = testLibraryCheckOut(t, b, s, "entity-c")
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Renew", func(t *testing.T) {
+		secret := *first.Secret
+		secret.IssueTime = time.Now()
+		secret.Increment = time.Duration(testMaxTTL) * time.Second * 2
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Secret:    &secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.LessOrEqual(t, resp.Secret.TTL, time.Duration(testMaxTTL)*time.Second)
+		require.NotEmpty(t, resp.Warnings)
+	})
+
+	t.Run("Check In by another entity - fail", func(t *testing.T) {
+		resp, err := testLibraryCheckIn(t, b, s, "entity-b", []string{"KEY1"})
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Check In", func(t *testing.T) {
+		resp, err := testLibraryCheckIn(t, b, s, "entity-a", nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"KEY1"}, resp.Data["check_ins"])
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "library/" + librarySetName + "/status",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, true, resp.Data["KEY1"].(map[string]interface{})["available"])
+		require.Equal(t, false, resp.Data["KEY2"].(map[string]interface{})["available"])
+	})
+
+	t.Run("Revoke stale lease", func(t *testing.T) {
+		// KEY1 was checked in explicitly, so revoking its original lease
+		// must not release a later check-out of the same key.
+		again, err := testLibraryCheckOut(t, b, s, "entity-c")
+		require.NoError(t, err)
+		require.Equal(t, "KEY1", again.Data["api_key"])
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    first.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		set, err := getLibrarySet(context.Background(), s, librarySetName)
+		require.NoError(t, err)
+		require.NotNil(t, set.Keys["KEY1"].CheckOut)
+		require.Equal(t, "entity-c", set.Keys["KEY1"].CheckOut.BorrowerEntityID)
+	})
+
+	t.Run("Delete Library Set with keys checked out - fail", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "library/" + librarySetName,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+// Utility function to check out a key from the test library set as the given entity
+func testLibraryCheckOut(t *testing.T, b *Backend, s logical.Storage, entityID string) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/" + librarySetName + "/check-out",
+		EntityID:  entityID,
+		Storage:   s,
+	})
+}
+
+// Utility function to check keys back in to the test library set as the given entity
+func testLibraryCheckIn(t *testing.T, b *Backend, s logical.Storage, entityID string, apiKeys []string) (*logical.Response, error) {
+	t.Helper()
+	d := map[string]interface{}{}
+	if apiKeys != nil {
+		d["api_keys"] = apiKeys
+	}
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/" + librarySetName + "/check-in",
+		EntityID:  entityID,
+		Data:      d,
+		Storage:   s,
+	})
+}
+
+func TestLibraryAdopt(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+
+	newKey := func(spec apiKeySpec) *confluentApiKey {
+		key, err := fake.createToken(context.Background(), spec)
+		require.NoError(t, err)
+		return key
+	}
+
+	write := func(op logical.Operation, apiKeys map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      "library/" + librarySetName,
+			Data:      map[string]interface{}{"api_keys": apiKeys},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	key := newKey(apiKeySpec{Owner: "sa-123"})
+
+	t.Run("Adopt Key with wrong secret - fail", func(t *testing.T) {
+		resp := write(logical.CreateOperation, map[string]interface{}{key.ApiKey: "wrong"})
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "rejected")
+	})
+
+	t.Run("Adopt Key while Confluent is unavailable - fail", func(t *testing.T) {
+		for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+			fake.failNext("verifyTokenSecret", status)
+			_, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      "library/" + librarySetName,
+				Data:      map[string]interface{}{"api_keys": map[string]interface{}{key.ApiKey: key.ApiSecret}},
+				Storage:   s,
+			})
+			require.ErrorContains(t, err, "temporary error")
+
+			set, err := getLibrarySet(context.Background(), s, librarySetName)
+			require.NoError(t, err)
+			require.Nil(t, set)
+		}
+	})
+
+	t.Run("Adopt resource scoped Key", func(t *testing.T) {
+		scoped := newKey(apiKeySpec{Owner: "sa-123", Resource: "lkc-123"})
+		resp := write(logical.CreateOperation, map[string]interface{}{scoped.ApiKey: scoped.ApiSecret})
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "verify=false")
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "library/scoped",
+			Data: map[string]interface{}{
+				"api_keys": map[string]interface{}{scoped.ApiKey: scoped.ApiSecret},
+				"verify":   false,
+			},
+			Storage: s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "library/scoped/status",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, false, resp.Data[scoped.ApiKey].(map[string]interface{})["verified"])
+	})
+
+	t.Run("Adopt Key", func(t *testing.T) {
+		resp := write(logical.CreateOperation, map[string]interface{}{key.ApiKey: key.ApiSecret})
+		require.Nil(t, resp)
+	})
+
+	t.Run("Update Key with wrong secret - fail", func(t *testing.T) {
+		resp := write(logical.UpdateOperation, map[string]interface{}{key.ApiKey: "wrong"})
+		require.True(t, resp.IsError())
+
+		set, err := getLibrarySet(context.Background(), s, librarySetName)
+		require.NoError(t, err)
+		require.Equal(t, key.ApiSecret, set.Keys[key.ApiKey].ApiSecret)
+	})
+}
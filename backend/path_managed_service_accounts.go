@@ -0,0 +1,278 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	managedServiceAccountStoragePrefix = "service-account/"
+)
+
+// managedServiceAccount is a Confluent service account created and owned by
+// Vault.
+type managedServiceAccount struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+}
+
+func (a *managedServiceAccount) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"id":           a.ID,
+		"display_name": a.DisplayName,
+		"description":  a.Description,
+	}
+}
+
+func pathManagedServiceAccounts(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "service-account/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the managed service account",
+					Required:    true,
+				},
+				"display_name": {
+					Type:        framework.TypeString,
+					Description: "Display name of the service account in Confluent. Defaults to the name. Cannot be changed after creation.",
+				},
+				"description": {
+					Type:        framework.TypeString,
+					Description: "Description of the service account in Confluent",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathManagedServiceAccountsRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathManagedServiceAccountsWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathManagedServiceAccountsWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathManagedServiceAccountsDelete,
+				},
+			},
+			ExistenceCheck:  b.pathManagedServiceAccountsExistenceCheck,
+			HelpSynopsis:    pathManagedServiceAccountsHelpSynopsis,
+			HelpDescription: pathManagedServiceAccountsHelpDescription,
+		},
+		{
+			Pattern: "service-account/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathManagedServiceAccountsList,
+				},
+			},
+			HelpSynopsis:    pathManagedServiceAccountsListHelpSynopsis,
+			HelpDescription: pathManagedServiceAccountsListHelpDescription,
+		},
+	}
+}
+
+const (
+	pathManagedServiceAccountsHelpSynopsis    = `Manages Confluent service accounts owned by Vault.`
+	pathManagedServiceAccountsHelpDescription = `
+This path creates, updates and deletes Confluent Cloud service accounts.
+Roles can reference a managed service account by name with the
+managed_service_account field. An account cannot be deleted while roles
+reference it or while it still owns API keys.
+`
+	pathManagedServiceAccountsListHelpSynopsis    = `List the service accounts managed by the Confluent backend`
+	pathManagedServiceAccountsListHelpDescription = `Managed service accounts will be listed by name.`
+)
+
+func (b *Backend) pathManagedServiceAccountsExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, req.Path)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func getManagedServiceAccount(ctx context.Context, s logical.Storage, name string) (*managedServiceAccount, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing service account name")
+	}
+
+	entry, err := s.Get(ctx, managedServiceAccountStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	account := new(managedServiceAccount)
+	if err := entry.DecodeJSON(account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func setManagedServiceAccount(ctx context.Context, s logical.Storage, name string, account *managedServiceAccount) error {
+	entry, err := logical.StorageEntryJSON(managedServiceAccountStoragePrefix+name, account)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func (b *Backend) pathManagedServiceAccountsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	account, err := getManagedServiceAccount(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if account == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: account.toResponseData(),
+	}, nil
+}
+
+func (b *Backend) pathManagedServiceAccountsWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	account, err := getManagedServiceAccount(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	description := d.Get("description").(string)
+
+	if account == nil {
+		if req.Operation != logical.CreateOperation {
+			return nil, errors.New("service account not found during update operation")
+		}
+
+		displayName := name
+		if displayNameRaw, ok := d.GetOk("display_name"); ok {
+			displayName = displayNameRaw.(string)
+		}
+
+		created, err := c.createServiceAccount(ctx, displayName, description)
+		if err != nil {
+			return nil, err
+		}
+
+		account = &managedServiceAccount{
+			ID:          created.GetId(),
+			DisplayName: created.GetDisplayName(),
+			Description: created.GetDescription(),
+		}
+	} else {
+		if displayName, ok := d.GetOk("display_name"); ok && displayName.(string) != account.DisplayName {
+			return logical.ErrorResponse("display_name cannot be changed after the service account is created"), nil
+		}
+
+		if _, ok := d.GetOk("description"); ok && description != account.Description {
+			if err := c.updateServiceAccount(ctx, account.ID, description); err != nil {
+				return nil, err
+			}
+			account.Description = description
+		}
+	}
+
+	if err := setManagedServiceAccount(ctx, req.Storage, name, account); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: account.toResponseData(),
+	}, nil
+}
+
+func (b *Backend) pathManagedServiceAccountsDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	account, err := getManagedServiceAccount(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if account == nil {
+		return nil, nil
+	}
+
+	roles, err := b.rolesUsingManagedServiceAccount(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) > 0 {
+		return logical.ErrorResponse("service account %q is still referenced by roles: %v", name, roles), nil
+	}
+
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := countTokens(ctx, c, account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		return logical.ErrorResponse("service account %q still owns %d API keys", name, count), nil
+	}
+
+	if err := c.deleteServiceAccount(ctx, account.ID); err != nil && !isNotFound(err) {
+		return nil, err
+	}
+
+	if err := req.Storage.Delete(ctx, managedServiceAccountStoragePrefix+name); err != nil {
+		return nil, fmt.Errorf("error deleting managed service account: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathManagedServiceAccountsList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, managedServiceAccountStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+// rolesUsingManagedServiceAccount returns the names of roles that reference
+// the managed service account.
+func (b *Backend) rolesUsingManagedServiceAccount(ctx context.Context, s logical.Storage, name string) ([]string, error) {
+	roleNames, err := s.List(ctx, "role/")
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	for _, roleName := range roleNames {
+		role, err := getRoleEntry(ctx, s, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil && role.ManagedServiceAccount == name {
+			roles = append(roles, roleName)
+		}
+	}
+	return roles, nil
+}
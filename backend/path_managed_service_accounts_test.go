@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestManagedServiceAccount(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	keyCount := 0
+	deleted := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/iam/v2/service-accounts":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			body["id"] = "sa-managed"
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(body)
+		case r.Method == http.MethodGet && r.URL.Path == "/iam/v2/api-keys":
+			data := make([]map[string]interface{}, keyCount)
+			for i := range data {
+				data[i] = map[string]interface{}{"id": "KEY"}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"api_version": "iam/v2", "kind": "ApiKeyList", "metadata": map[string]interface{}{}, "data": data})
+		case r.Method == http.MethodDelete && r.URL.Path == "/iam/v2/service-accounts/sa-managed":
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"username": username,
+		"password": password,
+		"url":      srv.URL,
+	}))
+
+	t.Run("Create Managed Service Account", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "service-account/payments",
+			Data:      map[string]interface{}{"description": "Payments team"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "sa-managed", resp.Data["id"])
+		require.Equal(t, "payments", resp.Data["display_name"])
+	})
+
+	t.Run("Reference Managed Service Account from Role", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"managed_service_account": "payments",
+			"skip_validation":         true,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		role, err := b.getRole(context.Background(), s, roleName)
+		require.NoError(t, err)
+		require.Equal(t, "sa-managed", role.ServiceAccount)
+
+		resp, err = testTokenRoleCreate(t, b, s, roleName+"-missing", map[string]interface{}{
+			"managed_service_account": "missing",
+			"skip_validation":         true,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Delete Managed Service Account in use - fail", func(t *testing.T) {
+		resp, err := testManagedServiceAccountDelete(b, s)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		_, err = testTokenRoleDelete(t, b, s)
+		require.NoError(t, err)
+
+		keyCount = 1
+		resp, err = testManagedServiceAccountDelete(b, s)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.False(t, deleted)
+	})
+
+	t.Run("Delete Managed Service Account", func(t *testing.T) {
+		keyCount = 0
+		resp, err := testManagedServiceAccountDelete(b, s)
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.True(t, deleted)
+	})
+}
+
+func testManagedServiceAccountDelete(b *Backend, s logical.Storage) (*logical.Response, error) {
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "service-account/payments",
+		Storage:   s,
+	})
+}
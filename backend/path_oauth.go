@@ -0,0 +1,422 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	oauthConfigStoragePath = "oauth/config"
+
+	defaultOAuthKeyRotationPeriod = 24 * time.Hour
+	minOAuthKeyRotationPeriod     = time.Hour
+
+	jwksPathSuffix = "/.well-known/jwks.json"
+)
+
+// oauthConfig describes the Confluent identity provider that trusts tokens
+// signed by this mount.
+type oauthConfig struct {
+	Issuer         string        `json:"issuer"`
+	JwksURI        string        `json:"jwks_uri"`
+	ProviderID     string        `json:"provider_id"`
+	RotationPeriod time.Duration `json:"rotation_period"`
+}
+
+func getOAuthConfig(ctx context.Context, s logical.Storage) (*oauthConfig, error) {
+	entry, err := s.Get(ctx, oauthConfigStoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	config := new(oauthConfig)
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, fmt.Errorf("error reading OAuth configuration: %w", err)
+	}
+	return config, nil
+}
+
+func setOAuthConfig(ctx context.Context, s logical.Storage, config *oauthConfig) error {
+	entry, err := logical.StorageEntryJSON(oauthConfigStoragePath, config)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func pathOAuth(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oauth/config",
+			Fields: map[string]*framework.FieldSchema{
+				"issuer": {
+					Type:        framework.TypeString,
+					Description: "Issuer of the tokens signed by Vault. Must be the externally reachable URL of this mount's oauth path, such as https://vault.example.com/v1/confluent/oauth.",
+					Required:    true,
+				},
+				"jwks_uri": {
+					Type:        framework.TypeString,
+					Description: "URL Confluent fetches the signing keys from. Defaults to the issuer followed by " + jwksPathSuffix + ".",
+				},
+				"key_rotation_period": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How often the signing key is rotated. Defaults to 24h.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathOAuthConfigRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathOAuthConfigWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathOAuthConfigWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathOAuthConfigDelete,
+				},
+			},
+			ExistenceCheck:  b.pathOAuthConfigExistenceCheck,
+			HelpSynopsis:    pathOAuthConfigHelpSyn,
+			HelpDescription: pathOAuthConfigHelpDesc,
+		},
+		{
+			Pattern: "oauth/rotate",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathOAuthRotate,
+				},
+			},
+			HelpSynopsis:    pathOAuthRotateHelpSyn,
+			HelpDescription: pathOAuthRotateHelpDesc,
+		},
+		{
+			Pattern: "oauth/\\.well-known/jwks\\.json",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathOAuthJwksRead,
+				},
+			},
+			HelpSynopsis:    pathOAuthJwksHelpSyn,
+			HelpDescription: pathOAuthJwksHelpDesc,
+		},
+	}
+}
+
+const (
+	pathOAuthConfigHelpSyn  = `Configure Vault as an OAuth token issuer for Confluent.`
+	pathOAuthConfigHelpDesc = `
+Writing this path generates a signing key and registers a Confluent identity
+provider that trusts tokens signed by it. Roles with credential_type=oauth
+then issue signed JWTs instead of API keys.
+`
+	pathOAuthRotateHelpSyn  = `Rotate the OAuth signing key.`
+	pathOAuthRotateHelpDesc = `
+Starts signing with the next key, which was published in the JWKS at the
+previous rotation, and publishes a new next key. The previous key stays
+published until the tokens it signed have expired.
+`
+	pathOAuthJwksHelpSyn  = `Public keys used to verify tokens issued by this mount.`
+	pathOAuthJwksHelpDesc = `
+This path is unauthenticated, so Confluent can fetch it as the identity
+provider's JWKS URI.
+`
+)
+
+func (b *Backend) pathOAuthConfigExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
+	config, err := getOAuthConfig(ctx, req.Storage)
+	if err != nil {
+		return false, err
+	}
+
+	return config != nil, nil
+}
+
+func (b *Backend) pathOAuthConfigRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := getOAuthConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return nil, nil
+	}
+
+	ring, err := getOAuthKeyRing(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"issuer":               config.Issuer,
+		"jwks_uri":             config.JwksURI,
+		"identity_provider_id": config.ProviderID,
+		"key_rotation_period":  int64(config.RotationPeriod.Seconds()),
+	}
+	if key := ring.current(); key != nil {
+		data["current_key_id"] = key.ID
+		data["current_key_created_at"] = key.CreatedAt.Format(time.RFC3339)
+	}
+	if ring.Next != nil {
+		data["next_key_id"] = ring.Next.ID
+		data["next_key_created_at"] = ring.Next.CreatedAt.Format(time.RFC3339)
+	}
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (b *Backend) pathOAuthConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	b.oauthLock.Lock()
+	defer b.oauthLock.Unlock()
+
+	config, err := getOAuthConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		config = &oauthConfig{RotationPeriod: defaultOAuthKeyRotationPeriod}
+	}
+
+	if issuer, ok := d.GetOk("issuer"); ok {
+		issuer := strings.TrimSuffix(issuer.(string), "/")
+		if config.ProviderID != "" && issuer != config.Issuer {
+			return logical.ErrorResponse("issuer cannot be changed once the identity provider has been created; delete oauth/config first"), nil
+		}
+		config.Issuer = issuer
+	}
+
+	if config.Issuer == "" {
+		return logical.ErrorResponse("issuer is required"), nil
+	}
+
+	if jwksURI, ok := d.GetOk("jwks_uri"); ok {
+		if config.ProviderID != "" && jwksURI.(string) != config.JwksURI {
+			return logical.ErrorResponse("jwks_uri cannot be changed once the identity provider has been created; delete oauth/config first"), nil
+		}
+		config.JwksURI = jwksURI.(string)
+	}
+
+	if config.JwksURI == "" {
+		config.JwksURI = config.Issuer + jwksPathSuffix
+	}
+
+	if period, ok := d.GetOk("key_rotation_period"); ok {
+		config.RotationPeriod = time.Duration(period.(int)) * time.Second
+	}
+
+	if config.RotationPeriod < minOAuthKeyRotationPeriod {
+		return logical.ErrorResponse("key_rotation_period cannot be less than %s", minOAuthKeyRotationPeriod), nil
+	}
+
+	// The signing key has to be published before Confluent first fetches the
+	// JWKS. A new ring has no next key to start signing with, so the first
+	// rotation only publishes one.
+	ring, err := getOAuthKeyRing(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if ring.current() == nil {
+		now := time.Now().UTC()
+		if ring.Next == nil {
+			if err := ring.rotate(now); err != nil {
+				return nil, err
+			}
+		}
+		if err := ring.rotate(now); err != nil {
+			return nil, err
+		}
+		if err := setOAuthKeyRing(ctx, req.Storage, ring); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.ProviderID == "" {
+		c, err := b.getClient(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		provider, err := createIdentityProvider(ctx, c, identityProvider{
+			DisplayName: "Vault " + config.Issuer,
+			Description: "Tokens issued by the Vault Confluent secrets engine",
+			Issuer:      config.Issuer,
+			JwksURI:     config.JwksURI,
+		})
+		if err != nil {
+			return nil, err
+		}
+		config.ProviderID = provider.ID
+	}
+
+	if err := setOAuthConfig(ctx, req.Storage, config); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathOAuthConfigDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	b.oauthLock.Lock()
+	defer b.oauthLock.Unlock()
+
+	config, err := getOAuthConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return nil, nil
+	}
+
+	roles, err := b.rolesWithCredentialType(ctx, req.Storage, credentialTypeOAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) > 0 {
+		return logical.ErrorResponse("the identity provider is still used by oauth roles: %v", roles), nil
+	}
+
+	if config.ProviderID != "" {
+		c, err := b.getClient(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := deleteIdentityProvider(ctx, c, config.ProviderID); err != nil && !isNotFound(err) {
+			return nil, err
+		}
+	}
+
+	if err := req.Storage.Delete(ctx, oauthKeysStoragePath); err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Delete(ctx, oauthConfigStoragePath); err != nil {
+		return nil, fmt.Errorf("error deleting OAuth configuration: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathOAuthRotate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := getOAuthConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("oauth/config has not been written"), nil
+	}
+
+	if err := b.rotateOAuthKey(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) rotateOAuthKey(ctx context.Context, s logical.Storage) error {
+	b.oauthLock.Lock()
+	defer b.oauthLock.Unlock()
+
+	ring, err := getOAuthKeyRing(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if err := ring.rotate(time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return setOAuthKeyRing(ctx, s, ring)
+}
+
+func (b *Backend) pathOAuthJwksRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ring, err := getOAuthKeyRing(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks, err := ring.jwks()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}, nil
+}
+
+// rotateOAuthKeyIfDue rotates the signing key once the next key has been
+// published for the configured rotation period.
+func (b *Backend) rotateOAuthKeyIfDue(ctx context.Context, req *logical.Request) error {
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary | consts.ReplicationPerformanceStandby) {
+		return nil
+	}
+
+	config, err := getOAuthConfig(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	ring, err := getOAuthKeyRing(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	if ring.Next != nil && time.Since(ring.Next.CreatedAt) < config.RotationPeriod {
+		return nil
+	}
+
+	return b.rotateOAuthKey(ctx, req.Storage)
+}
+
+// rolesWithCredentialType returns the names of roles issuing the given kind
+// of credential.
+func (b *Backend) rolesWithCredentialType(ctx context.Context, s logical.Storage, credentialType string) ([]string, error) {
+	roleNames, err := s.List(ctx, "role/")
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	for _, roleName := range roleNames {
+		role, err := b.getRole(ctx, s, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil && role.credentialType() == credentialType {
+			roles = append(roles, roleName)
+		}
+	}
+	return roles, nil
+}
@@ -0,0 +1,216 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const (
+	testIssuer = "https://vault.example.com/v1/confluent/oauth"
+)
+
+func TestOAuthRole(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+
+	t.Run("Create OAuth Role before OAuth config - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "workload", map[string]interface{}{
+			"credential_type": credentialTypeOAuth,
+			"skip_validation": true,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	var providerID string
+	t.Run("Write OAuth Config", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "oauth/config",
+			Data:      map[string]interface{}{"issuer": testIssuer},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "oauth/config",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		providerID = resp.Data["identity_provider_id"].(string)
+		provider := fake.resource(identityProvidersPath + "/" + providerID)
+		require.Equal(t, testIssuer, provider["issuer"])
+		require.Equal(t, testIssuer+jwksPathSuffix, provider["jwks_uri"])
+		require.NotEmpty(t, resp.Data["current_key_id"])
+		require.NotEmpty(t, resp.Data["next_key_id"])
+		require.ElementsMatch(t, []string{resp.Data["current_key_id"].(string), resp.Data["next_key_id"].(string)}, testOAuthKeyIDs(t, b, s))
+	})
+
+	t.Run("Create OAuth Role with reserved claim - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "workload", map[string]interface{}{
+			"credential_type": credentialTypeOAuth,
+			"claims":          map[string]interface{}{"sub": "someone-else"},
+			"skip_validation": true,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	var poolID string
+	t.Run("Create OAuth Role", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "workload", map[string]interface{}{
+			"credential_type": credentialTypeOAuth,
+			"resource":        "lkc-123",
+			"environment":     "env-123",
+			"claims":          map[string]interface{}{"team": "payments"},
+			"ttl":             "5m",
+			"skip_validation": true,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		role, err := b.getRole(context.Background(), s, "workload")
+		require.NoError(t, err)
+		poolID = role.IdentityPoolID
+
+		pool := fake.resource(identityPoolsPath(providerID) + "/" + poolID)
+		require.Equal(t, `claims.sub == "vault-role:workload"`, pool["filter"])
+		require.Equal(t, defaultIdentityClaim, pool["identity_claim"])
+	})
+
+	var token string
+	t.Run("Generate Token", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "workload")
+		require.NoError(t, err)
+		require.Nil(t, resp.Secret)
+		require.Equal(t, poolID, resp.Data["identity_pool_id"])
+		require.Equal(t, "lkc-123", resp.Data["sasl_extensions"].(map[string]interface{})["logicalCluster"])
+		token = resp.Data["token"].(string)
+
+		claims := testVerifyOAuthToken(t, b, s, token)
+		require.Equal(t, testIssuer, claims["iss"])
+		require.Equal(t, "vault-role:workload", claims["sub"])
+		require.Equal(t, "payments", claims["team"])
+	})
+
+	t.Run("Rotation is not due while the next Key is new", func(t *testing.T) {
+		before, err := getOAuthKeyRing(context.Background(), s)
+		require.NoError(t, err)
+
+		require.NoError(t, b.rotateOAuthKeyIfDue(context.Background(), &logical.Request{Storage: s}))
+
+		after, err := getOAuthKeyRing(context.Background(), s)
+		require.NoError(t, err)
+		require.Equal(t, before.current().ID, after.current().ID)
+		require.Equal(t, before.Next.ID, after.Next.ID)
+	})
+
+	t.Run("Rotate Signing Key", func(t *testing.T) {
+		published := testOAuthKeyIDs(t, b, s)
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "oauth/rotate",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		ring, err := getOAuthKeyRing(context.Background(), s)
+		require.NoError(t, err)
+		require.Len(t, ring.Keys, 2)
+		require.NotContains(t, published, ring.Next.ID)
+
+		// Tokens signed by the retired key still verify.
+		testVerifyOAuthToken(t, b, s, token)
+
+		// The new signing key was published before the rotation.
+		resp, err := testCredentialsRead(b, s, "workload")
+		require.NoError(t, err)
+		parsed, err := jwt.ParseSigned(resp.Data["token"].(string), []jose.SignatureAlgorithm{jose.RS256})
+		require.NoError(t, err)
+		require.Equal(t, ring.current().ID, parsed.Headers[0].KeyID)
+		require.Contains(t, published, parsed.Headers[0].KeyID)
+	})
+
+	t.Run("Delete OAuth Config with roles - fail", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "oauth/config",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Delete OAuth Role", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "role/workload",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, fake.resource(identityPoolsPath(providerID)+"/"+poolID))
+	})
+
+	t.Run("Delete OAuth Config", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "oauth/config",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, fake.resource(identityProvidersPath+"/"+providerID))
+	})
+}
+
+// Utility function to list the key IDs published in the mount's JWKS
+func testOAuthKeyIDs(t *testing.T, b *Backend, s logical.Storage) []string {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation:       logical.ReadOperation,
+		Path:            "oauth/.well-known/jwks.json",
+		Storage:         s,
+		Unauthenticated: true,
+	})
+	require.NoError(t, err)
+
+	var jwks jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &jwks))
+
+	var ids []string
+	for _, key := range jwks.Keys {
+		ids = append(ids, key.KeyID)
+	}
+	return ids
+}
+
+// Utility function to verify a token against the mount's JWKS and return its claims
+func testVerifyOAuthToken(t *testing.T, b *Backend, s logical.Storage, token string) map[string]interface{} {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation:       logical.ReadOperation,
+		Path:            "oauth/.well-known/jwks.json",
+		Storage:         s,
+		Unauthenticated: true,
+	})
+	require.NoError(t, err)
+
+	var jwks jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &jwks))
+
+	parsed, err := jwt.ParseSigned(token, []jose.SignatureAlgorithm{jose.RS256})
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{}
+	require.NoError(t, parsed.Claims(jwks, &claims))
+	return claims
+}
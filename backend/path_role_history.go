@@ -0,0 +1,386 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	roleHistoryStoragePrefix = "role-history/"
+
+	// roleHistoryLimit is the number of versions kept per role. Older
+	// versions are removed as new ones are recorded.
+	roleHistoryLimit = 50
+
+	roleChangeCreate   = "create"
+	roleChangeUpdate   = "update"
+	roleChangeRollback = "rollback"
+	roleChangeDelete   = "delete"
+)
+
+// roleVersion is a role as it was stored by a write or rollback, with who
+// made the change, when, and how it differs from the version before it.
+// Deletions are recorded as versions without a role.
+type roleVersion struct {
+	Version             int                        `json:"version"`
+	Change              string                     `json:"change"`
+	RolledBackTo        int                        `json:"rolled_back_to,omitempty"`
+	EntityID            string                     `json:"entity_id,omitempty"`
+	DisplayName         string                     `json:"display_name,omitempty"`
+	ClientTokenAccessor string                     `json:"client_token_accessor,omitempty"`
+	ModifiedAt          time.Time                  `json:"modified_at"`
+	Diff                map[string]roleFieldChange `json:"diff,omitempty"`
+	Role                *confluentRoleEntry        `json:"role,omitempty"`
+}
+
+// roleFieldChange is the value of a role field before and after a change.
+type roleFieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+func (v *roleVersion) toResponseData() map[string]interface{} {
+	data := map[string]interface{}{
+		"version":               v.Version,
+		"change":                v.Change,
+		"entity_id":             v.EntityID,
+		"display_name":          v.DisplayName,
+		"client_token_accessor": v.ClientTokenAccessor,
+		"modified_at":           v.ModifiedAt.Format(time.RFC3339),
+		"diff":                  diffResponseData(v.Diff),
+	}
+	if v.RolledBackTo != 0 {
+		data["rolled_back_to"] = v.RolledBackTo
+	}
+	return data
+}
+
+// diffResponseData returns the diff with each field's change as a map of
+// from and to.
+func diffResponseData(diff map[string]roleFieldChange) map[string]interface{} {
+	data := make(map[string]interface{}, len(diff))
+	for field, change := range diff {
+		data[field] = map[string]interface{}{
+			"from": change.From,
+			"to":   change.To,
+		}
+	}
+	return data
+}
+
+// diffRoles returns the fields whose values differ between two roles, either
+// of which may be nil. The LDAP password is reported as changed without its
+// values.
+func diffRoles(from, to *confluentRoleEntry) map[string]roleFieldChange {
+	if from == nil {
+		from = &confluentRoleEntry{}
+	}
+	if to == nil {
+		to = &confluentRoleEntry{}
+	}
+	fromData, toData := from.toResponseData(), to.toResponseData()
+
+	diff := map[string]roleFieldChange{}
+	for field, toValue := range toData {
+		fromValue := fromData[field]
+		if !isEmptyValue(fromValue) || !isEmptyValue(toValue) {
+			if !reflect.DeepEqual(fromValue, toValue) {
+				diff[field] = roleFieldChange{From: fromValue, To: toValue}
+			}
+		}
+	}
+	for field, fromValue := range fromData {
+		if _, ok := toData[field]; !ok && !isEmptyValue(fromValue) {
+			diff[field] = roleFieldChange{From: fromValue}
+		}
+	}
+
+	if from.LDAPPassword != to.LDAPPassword {
+		diff["ldap_password"] = roleFieldChange{From: redactedLogValue, To: redactedLogValue}
+	}
+	return diff
+}
+
+// isEmptyValue reports whether v is nil, a zero value or an empty
+// collection, so unset and empty fields are not reported as changes.
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+// roleVersions returns the recorded versions of the role, oldest first.
+func roleVersions(ctx context.Context, s logical.Storage, name string) ([]int, error) {
+	keys, err := s.List(ctx, roleHistoryStoragePrefix+name+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0, len(keys))
+	for _, key := range keys {
+		version, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+func getRoleVersion(ctx context.Context, s logical.Storage, name string, version int) (*roleVersion, error) {
+	entry, err := s.Get(ctx, fmt.Sprintf("%s%s/%d", roleHistoryStoragePrefix, name, version))
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	v := new(roleVersion)
+	if err := entry.DecodeJSON(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// recordRoleVersion stores roleEntry as the next version of the role, and
+// removes the versions beyond roleHistoryLimit. A nil roleEntry records the
+// deletion of the role.
+func (b *Backend) recordRoleVersion(ctx context.Context, req *logical.Request, name string, previous, roleEntry *confluentRoleEntry, version *roleVersion) error {
+	// Concurrent writes would otherwise number their versions the same.
+	b.roleHistoryLock.Lock()
+	defer b.roleHistoryLock.Unlock()
+
+	versions, err := roleVersions(ctx, req.Storage, name)
+	if err != nil {
+		return err
+	}
+
+	version.Version = 1
+	if len(versions) > 0 {
+		version.Version = versions[len(versions)-1] + 1
+	}
+	version.EntityID = req.EntityID
+	version.DisplayName = req.DisplayName
+	version.ClientTokenAccessor = req.ClientTokenAccessor
+	version.ModifiedAt = time.Now().UTC()
+	version.Diff = diffRoles(previous, roleEntry)
+	version.Role = roleEntry
+
+	entry, err := logical.StorageEntryJSON(fmt.Sprintf("%s%s/%d", roleHistoryStoragePrefix, name, version.Version), version)
+	if err != nil {
+		return err
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	versions = append(versions, version.Version)
+	for len(versions) > roleHistoryLimit {
+		if err := req.Storage.Delete(ctx, fmt.Sprintf("%s%s/%d", roleHistoryStoragePrefix, name, versions[0])); err != nil {
+			return err
+		}
+		versions = versions[1:]
+	}
+	return nil
+}
+
+func pathRoleHistory(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "role/" + framework.GenericNameRegex("name") + "/history",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the role",
+					Required:    true,
+				},
+				"version": {
+					Type:        framework.TypeInt,
+					Description: "Version to read, including the role as it was stored. If not set, all versions are listed without their roles.",
+					Query:       true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathRoleHistoryRead,
+				},
+			},
+			HelpSynopsis:    pathRoleHistoryHelpSynopsis,
+			HelpDescription: pathRoleHistoryHelpDescription,
+		},
+		{
+			Pattern: "role/" + framework.GenericNameRegex("name") + "/rollback",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the role",
+					Required:    true,
+				},
+				"version": {
+					Type:        framework.TypeInt,
+					Description: "Version of the role to restore",
+					Required:    true,
+				},
+				"skip_validation": {
+					Type:        framework.TypeBool,
+					Description: "Do not check that the service account, resource and environment of the restored version exist in Confluent.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathRoleRollback,
+				},
+			},
+			HelpSynopsis:    pathRoleRollbackHelpSynopsis,
+			HelpDescription: pathRoleRollbackHelpDescription,
+		},
+	}
+}
+
+const (
+	pathRoleHistoryHelpSynopsis    = `Read the change history of a role.`
+	pathRoleHistoryHelpDescription = `
+Every write, rollback and deletion of a role is recorded as a new version,
+with the entity, token display name and accessor that made the change, when
+it was made and the fields it changed. The last 50 versions are kept, also
+after the role is deleted. Read with version set to get the role as it was
+stored by that version.
+`
+	pathRoleRollbackHelpSynopsis    = `Restore an earlier version of a role.`
+	pathRoleRollbackHelpDescription = `
+This path writes the role as it was stored by an earlier version. The
+restored role is validated and applied like any other write, and recorded
+as a new version.
+`
+)
+
+func (b *Backend) pathRoleHistoryRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	if version, ok := d.GetOk("version"); ok {
+		v, err := getRoleVersion(ctx, req.Storage, name, version.(int))
+		if err != nil {
+			return nil, err
+		}
+
+		if v == nil {
+			return logical.ErrorResponse("version %d of role %q does not exist", version, name), nil
+		}
+
+		data := v.toResponseData()
+		if v.Role != nil {
+			data["role"] = v.Role.toResponseData()
+		}
+		return &logical.Response{Data: data}, nil
+	}
+
+	versions, err := roleVersions(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) == 0 {
+		return nil, nil
+	}
+
+	history := make([]map[string]interface{}, 0, len(versions))
+	for _, version := range versions {
+		v, err := getRoleVersion(ctx, req.Storage, name, version)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			history = append(history, v.toResponseData())
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"current_version": versions[len(versions)-1],
+			"versions":        history,
+		},
+	}, nil
+}
+
+func (b *Backend) pathRoleRollback(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	version := d.Get("version").(int)
+
+	current, err := getRoleEntry(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		return logical.ErrorResponse("role %q does not exist", name), nil
+	}
+
+	v, err := getRoleVersion(ctx, req.Storage, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return logical.ErrorResponse("version %d of role %q does not exist", version, name), nil
+	}
+
+	if v.Role == nil {
+		return logical.ErrorResponse("version %d of role %q is a deletion, and cannot be restored", version, name), nil
+	}
+
+	restored := *v.Role
+	if err := checkConnectionType(ctx, req.Storage, restored.credentialType()); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// The identity pool is managed for the role, and kept when an OAuth role
+	// is rolled back.
+	if restored.credentialType() == credentialTypeOAuth && current.credentialType() == credentialTypeOAuth {
+		restored.IdentityPoolID = current.IdentityPoolID
+	}
+
+	var template *roleTemplate
+	if restored.Template != "" {
+		template, err = getRoleTemplate(ctx, req.Storage, restored.Template)
+		if err != nil {
+			return nil, err
+		}
+
+		if template == nil {
+			return logical.ErrorResponse("role template %q of version %d no longer exists", restored.Template, version), nil
+		}
+	}
+
+	if restored.ManagedServiceAccount != "" {
+		account, err := getManagedServiceAccount(ctx, req.Storage, restored.ManagedServiceAccount)
+		if err != nil {
+			return nil, err
+		}
+
+		if account == nil {
+			return logical.ErrorResponse("managed service account %q of version %d no longer exists", restored.ManagedServiceAccount, version), nil
+		}
+		restored.ServiceAccount = account.ID
+	}
+
+	return b.saveRole(ctx, req, name, current, &restored, template, !d.Get("skip_validation").(bool), &roleVersion{
+		Change:       roleChangeRollback,
+		RolledBackTo: version,
+	})
+}
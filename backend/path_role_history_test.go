@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func testRoleHistoryRead(t *testing.T, b *Backend, s logical.Storage, name string, data map[string]interface{}) *logical.Response {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "role/" + name + "/history",
+		Data:      data,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	return resp
+}
+
+func TestRoleHistory(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+	fake.addServiceAccount("sa-123", "orders")
+	fake.addServiceAccount("sa-456", "payments")
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"username": username,
+		"password": password,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+		"ttl":             testTTL,
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "role/orders",
+		Data:        map[string]interface{}{"service_account": "sa-456"},
+		Storage:     s,
+		EntityID:    "entity-1",
+		DisplayName: "token-ops",
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	t.Run("List Versions", func(t *testing.T) {
+		resp := testRoleHistoryRead(t, b, s, "orders", nil)
+		require.Equal(t, 2, resp.Data["current_version"])
+
+		versions := resp.Data["versions"].([]map[string]interface{})
+		require.Len(t, versions, 2)
+		require.Equal(t, roleChangeCreate, versions[0]["change"])
+		require.Equal(t, roleChangeUpdate, versions[1]["change"])
+		require.Equal(t, "entity-1", versions[1]["entity_id"])
+		require.Equal(t, "token-ops", versions[1]["display_name"])
+		require.Equal(t, map[string]interface{}{
+			"service_account": map[string]interface{}{"from": "sa-123", "to": "sa-456"},
+		}, versions[1]["diff"])
+	})
+
+	t.Run("Read Version", func(t *testing.T) {
+		resp := testRoleHistoryRead(t, b, s, "orders", map[string]interface{}{"version": 1})
+		require.Equal(t, "sa-123", resp.Data["role"].(map[string]interface{})["service_account"])
+
+		resp = testRoleHistoryRead(t, b, s, "orders", map[string]interface{}{"version": 9})
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/orders/rollback",
+			Data:      map[string]interface{}{"version": 1},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		role, err := getRoleEntry(context.Background(), s, "orders")
+		require.NoError(t, err)
+		require.Equal(t, "sa-123", role.ServiceAccount)
+
+		resp = testRoleHistoryRead(t, b, s, "orders", map[string]interface{}{"version": 3})
+		require.Equal(t, roleChangeRollback, resp.Data["change"])
+		require.Equal(t, 1, resp.Data["rolled_back_to"])
+	})
+
+	t.Run("Rollback to deleted Service Account - fail", func(t *testing.T) {
+		require.NoError(t, fake.deleteServiceAccount(context.Background(), "sa-456"))
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/orders/rollback",
+			Data:      map[string]interface{}{"version": 2},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Delete Role keeps History", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:           logical.DeleteOperation,
+			Path:                "role/orders",
+			Storage:             s,
+			EntityID:            "entity-2",
+			ClientTokenAccessor: "accessor-2",
+		})
+		require.NoError(t, err)
+
+		resp := testRoleHistoryRead(t, b, s, "orders", nil)
+		require.Equal(t, 4, resp.Data["current_version"])
+
+		deleted := resp.Data["versions"].([]map[string]interface{})[3]
+		require.Equal(t, roleChangeDelete, deleted["change"])
+		require.Equal(t, "entity-2", deleted["entity_id"])
+		require.Equal(t, "accessor-2", deleted["client_token_accessor"])
+		require.Equal(t, map[string]interface{}{"from": "sa-123", "to": ""},
+			deleted["diff"].(map[string]interface{})["service_account"])
+
+		resp = testRoleHistoryRead(t, b, s, "orders", map[string]interface{}{"version": 4})
+		require.NotContains(t, resp.Data, "role")
+	})
+
+	t.Run("Recreate Role continues History", func(t *testing.T) {
+		_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"service_account": "sa-123",
+		})
+		require.NoError(t, err)
+
+		resp := testRoleHistoryRead(t, b, s, "orders", nil)
+		require.Equal(t, 5, resp.Data["current_version"])
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/orders/rollback",
+			Data:      map[string]interface{}{"version": 4},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+func TestRoleHistoryLimit(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < roleHistoryLimit+5; i++ {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/orders",
+			Data:      map[string]interface{}{"ttl": i + 1},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+	}
+
+	versions, err := roleVersions(context.Background(), s, "orders")
+	require.NoError(t, err)
+	require.Len(t, versions, roleHistoryLimit)
+	require.Equal(t, roleHistoryLimit+6, versions[len(versions)-1])
+}
@@ -0,0 +1,380 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/go-sockaddr"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"strings"
+	"time"
+)
+
+const (
+	roleTemplateStoragePrefix = "role-template/"
+)
+
+// roleTemplate holds settings shared by the roles that reference it. Roles
+// inherit every setting they leave unset.
+type roleTemplate struct {
+	ServiceAccount string        `json:"service_account,omitempty"`
+	Description    string        `json:"description,omitempty"`
+	TTL            time.Duration `json:"ttl,omitempty"`
+	MaxTTL         time.Duration `json:"max_ttl,omitempty"`
+
+	BoundCIDRs            []*sockaddr.SockAddrMarshaler `json:"bound_cidrs,omitempty"`
+	AllowedEntityIDs      []string                      `json:"allowed_entity_ids,omitempty"`
+	AllowedGroups         []string                      `json:"allowed_groups,omitempty"`
+	AllowedMountAccessors []string                      `json:"allowed_mount_accessors,omitempty"`
+}
+
+func (t *roleTemplate) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"service_account":         t.ServiceAccount,
+		"description":             t.Description,
+		"ttl":                     t.TTL.Seconds(),
+		"max_ttl":                 t.MaxTTL.Seconds(),
+		"bound_cidrs":             (&confluentRoleEntry{BoundCIDRs: t.BoundCIDRs}).boundCIDRStrings(),
+		"allowed_entity_ids":      t.AllowedEntityIDs,
+		"allowed_groups":          t.AllowedGroups,
+		"allowed_mount_accessors": t.AllowedMountAccessors,
+	}
+}
+
+// withTemplate returns a copy of the role with the settings it leaves unset
+// taken from the template.
+func (r *confluentRoleEntry) withTemplate(t *roleTemplate) *confluentRoleEntry {
+	effective := *r
+	if t == nil {
+		return &effective
+	}
+
+	if effective.ServiceAccount == "" && effective.requiresServiceAccount() {
+		effective.ServiceAccount = t.ServiceAccount
+	}
+	if effective.Description == "" {
+		effective.Description = t.Description
+	}
+	if effective.TTL == 0 {
+		effective.TTL = t.TTL
+	}
+	if effective.MaxTTL == 0 {
+		effective.MaxTTL = t.MaxTTL
+	}
+	if len(effective.BoundCIDRs) == 0 {
+		effective.BoundCIDRs = t.BoundCIDRs
+	}
+	if len(effective.AllowedEntityIDs) == 0 {
+		effective.AllowedEntityIDs = t.AllowedEntityIDs
+	}
+	if len(effective.AllowedGroups) == 0 {
+		effective.AllowedGroups = t.AllowedGroups
+	}
+	if len(effective.AllowedMountAccessors) == 0 {
+		effective.AllowedMountAccessors = t.AllowedMountAccessors
+	}
+	return &effective
+}
+
+func pathRoleTemplates(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "role-template/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the role template",
+					Required:    true,
+				},
+				"service_account": {
+					Type:        framework.TypeString,
+					Description: "Confluent Cloud service account of roles that do not set one",
+				},
+				"description": {
+					Type:        framework.TypeString,
+					Description: "Description of roles that do not set one",
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Default lease of roles that do not set one",
+				},
+				"max_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Maximum lease of roles that do not set one",
+				},
+				"bound_cidrs": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "CIDR blocks that credential requests must come from, for roles that do not set them",
+				},
+				"allowed_entity_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "IDs of the entities allowed to request credentials, for roles that do not set them",
+				},
+				"allowed_groups": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Names or IDs of identity groups allowed to request credentials, for roles that do not set them",
+				},
+				"allowed_mount_accessors": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Accessors of auth mounts the requesting entity must have an alias on, for roles that do not set them. This constrains the entity, not the auth mount the request's token was issued by",
+				},
+				"skip_validation": {
+					Type:        framework.TypeBool,
+					Description: "Do not check that a changed service account exists in Confluent for the roles that inherit it.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathRoleTemplatesRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathRoleTemplatesWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathRoleTemplatesWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathRoleTemplatesDelete,
+				},
+			},
+			ExistenceCheck:  b.pathRoleTemplatesExistenceCheck,
+			HelpSynopsis:    pathRoleTemplatesHelpSynopsis,
+			HelpDescription: pathRoleTemplatesHelpDescription,
+		},
+		{
+			Pattern: "role-template/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathRoleTemplatesList,
+				},
+			},
+			HelpSynopsis:    pathRoleTemplatesListHelpSynopsis,
+			HelpDescription: pathRoleTemplatesListHelpDescription,
+		},
+	}
+}
+
+const (
+	pathRoleTemplatesHelpSynopsis    = `Manages settings shared by several roles.`
+	pathRoleTemplatesHelpDescription = `
+This path manages role templates. A role references a template with its
+template field and inherits the template's service account, description,
+TTLs and request limits unless it sets them itself. Changes to a template
+apply to its roles right away, and are rejected if any of its roles would
+become invalid. A template cannot be deleted while roles reference it.
+`
+	pathRoleTemplatesListHelpSynopsis    = `List the role templates of the Confluent backend`
+	pathRoleTemplatesListHelpDescription = `Role templates will be listed by name.`
+)
+
+func (b *Backend) pathRoleTemplatesExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, req.Path)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func getRoleTemplate(ctx context.Context, s logical.Storage, name string) (*roleTemplate, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing role template name")
+	}
+
+	entry, err := s.Get(ctx, roleTemplateStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	template := new(roleTemplate)
+	if err := entry.DecodeJSON(template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func setRoleTemplate(ctx context.Context, s logical.Storage, name string, template *roleTemplate) error {
+	entry, err := logical.StorageEntryJSON(roleTemplateStoragePrefix+name, template)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func (b *Backend) pathRoleTemplatesRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	template, err := getRoleTemplate(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if template == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: template.toResponseData(),
+	}, nil
+}
+
+func (b *Backend) pathRoleTemplatesWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	template, err := getRoleTemplate(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if template == nil {
+		if req.Operation != logical.CreateOperation {
+			return nil, errors.New("role template not found during update operation")
+		}
+		template = new(roleTemplate)
+	}
+
+	previousServiceAccount := template.ServiceAccount
+	if serviceAccount, ok := d.GetOk("service_account"); ok {
+		template.ServiceAccount = serviceAccount.(string)
+	}
+
+	if description, ok := d.GetOk("description"); ok {
+		template.Description = description.(string)
+	}
+
+	if ttl, ok := d.GetOk("ttl"); ok {
+		template.TTL = time.Duration(ttl.(int)) * time.Second
+	}
+
+	if maxTTL, ok := d.GetOk("max_ttl"); ok {
+		template.MaxTTL = time.Duration(maxTTL.(int)) * time.Second
+	}
+
+	if boundCIDRs, ok := d.GetOk("bound_cidrs"); ok {
+		parsed, err := parseutil.ParseAddrs(boundCIDRs)
+		if err != nil {
+			return logical.ErrorResponse("invalid bound_cidrs: %s", err), nil
+		}
+		template.BoundCIDRs = parsed
+	}
+
+	if entityIDs, ok := d.GetOk("allowed_entity_ids"); ok {
+		template.AllowedEntityIDs = entityIDs.([]string)
+	}
+
+	if groups, ok := d.GetOk("allowed_groups"); ok {
+		template.AllowedGroups = groups.([]string)
+	}
+
+	if accessors, ok := d.GetOk("allowed_mount_accessors"); ok {
+		template.AllowedMountAccessors = accessors.([]string)
+	}
+
+	if template.MaxTTL != 0 && template.TTL > template.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+
+	// A changed service account is looked up in Confluent for the roles that
+	// inherit it, the same way it would be if it were written to the roles.
+	validate := template.ServiceAccount != previousServiceAccount && !d.Get("skip_validation").(bool)
+	if resp, err := b.checkTemplateRoles(ctx, req.Storage, name, template, validate); resp != nil || err != nil {
+		return resp, err
+	}
+
+	if err := setRoleTemplate(ctx, req.Storage, name, template); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathRoleTemplatesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	roles, err := rolesUsingTemplate(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) > 0 {
+		return logical.ErrorResponse("role template %q is still referenced by roles: %v", name, roles), nil
+	}
+
+	if err := req.Storage.Delete(ctx, roleTemplateStoragePrefix+name); err != nil {
+		return nil, fmt.Errorf("error deleting role template: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathRoleTemplatesList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, roleTemplateStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+// checkTemplateRoles runs checkRole on every role that references the
+// template with the template's new settings, so a template change cannot
+// make its roles invalid. With validate set, the targets of roles inheriting
+// the template's service account are looked up in Confluent.
+func (b *Backend) checkTemplateRoles(ctx context.Context, s logical.Storage, name string, template *roleTemplate, validate bool) (*logical.Response, error) {
+	roleNames, err := rolesUsingTemplate(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var invalid []string
+	for _, roleName := range roleNames {
+		role, err := getRoleEntry(ctx, s, roleName)
+		if err != nil {
+			return nil, err
+		}
+
+		if role == nil {
+			continue
+		}
+
+		inherits := role.ServiceAccount == "" && role.requiresServiceAccount()
+		resp, err := b.checkRole(ctx, s, role, template, validate && inherits)
+		if err != nil {
+			return nil, fmt.Errorf("error checking role %q: %w", roleName, err)
+		}
+		if resp.IsError() {
+			invalid = append(invalid, fmt.Sprintf("%s: %s", roleName, resp.Error()))
+		}
+	}
+
+	if len(invalid) > 0 {
+		return logical.ErrorResponse("the template change would make %d of %d roles using it invalid; %s",
+			len(invalid), len(roleNames), strings.Join(invalid, "; ")), nil
+	}
+	return nil, nil
+}
+
+// rolesUsingTemplate returns the names of roles that reference the template.
+func rolesUsingTemplate(ctx context.Context, s logical.Storage, name string) ([]string, error) {
+	roleNames, err := s.List(ctx, "role/")
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	for _, roleName := range roleNames {
+		role, err := getRoleEntry(ctx, s, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil && role.Template == name {
+			roles = append(roles, roleName)
+		}
+	}
+	return roles, nil
+}
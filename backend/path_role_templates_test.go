@@ -0,0 +1,193 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func testRoleTemplateWrite(t *testing.T, b logical.Backend, s logical.Storage, op logical.Operation, name string, data map[string]interface{}) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: op,
+		Path:      "role-template/" + name,
+		Data:      data,
+		Storage:   s,
+	})
+}
+
+func TestRoleTemplates(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	t.Run("Create Template", func(t *testing.T) {
+		resp, err := testRoleTemplateWrite(t, b, s, logical.CreateOperation, "streaming", map[string]interface{}{
+			"service_account": "sa-123",
+			"description":     "Streaming applications",
+			"ttl":             testTTL,
+			"max_ttl":         testMaxTTL,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("Create Role with missing Template - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"template": "missing",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Create Role from Template", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"template": "streaming",
+			"ttl":      60,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "role/orders",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "", resp.Data["service_account"])
+		require.Equal(t, "streaming", resp.Data["template"])
+
+		role, err := b.getRole(context.Background(), s, "orders")
+		require.NoError(t, err)
+		require.Equal(t, "sa-123", role.ServiceAccount)
+		require.Equal(t, "Streaming applications", role.Description)
+		require.Equal(t, 60*time.Second, role.TTL)
+		require.Equal(t, time.Duration(testMaxTTL)*time.Second, role.MaxTTL)
+	})
+
+	t.Run("Read Effective Role after Template change", func(t *testing.T) {
+		_, err := testRoleTemplateWrite(t, b, s, logical.UpdateOperation, "streaming", map[string]interface{}{
+			"service_account": "sa-456",
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "role/orders/effective",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "sa-456", resp.Data["service_account"])
+		require.Equal(t, float64(60), resp.Data["ttl"])
+		require.Equal(t, float64(testMaxTTL), resp.Data["max_ttl"])
+	})
+
+	t.Run("Create Role exceeding Template max_ttl - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "payments", map[string]interface{}{
+			"template": "streaming",
+			"ttl":      testMaxTTL + 1,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Delete Template in use - fail", func(t *testing.T) {
+		resp, err := testRoleTemplateWrite(t, b, s, logical.DeleteOperation, "streaming", nil)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "orders")
+	})
+
+	t.Run("List Templates", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "role-template/",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"streaming"}, resp.Data["keys"])
+	})
+}
+
+func TestRoleTemplateChanges(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+	fake.addServiceAccount("sa-123", "orders")
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"username": username,
+		"password": password,
+	}))
+
+	resp, err := testRoleTemplateWrite(t, b, s, logical.CreateOperation, "streaming", map[string]interface{}{
+		"service_account": "sa-123",
+		"max_ttl":         1800,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	for name, data := range map[string]map[string]interface{}{
+		"orders":     {"template": "streaming", "max_ttl": 600},
+		"breakglass": {"template": "streaming", "credential_type": credentialTypeUser, "allowed_user_ids": "u-123", "skip_validation": true},
+	} {
+		resp, err := testTokenRoleCreate(t, b, s, name, data)
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	}
+
+	t.Run("Raise max_ttl above user Role cap - fail", func(t *testing.T) {
+		resp, err := testRoleTemplateWrite(t, b, s, logical.UpdateOperation, "streaming", map[string]interface{}{
+			"max_ttl": 86400,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "breakglass")
+	})
+
+	t.Run("Raise ttl above Role max_ttl - fail", func(t *testing.T) {
+		resp, err := testRoleTemplateWrite(t, b, s, logical.UpdateOperation, "streaming", map[string]interface{}{
+			"ttl": 900,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "orders")
+	})
+
+	t.Run("Change to missing service account - fail", func(t *testing.T) {
+		resp, err := testRoleTemplateWrite(t, b, s, logical.UpdateOperation, "streaming", map[string]interface{}{
+			"service_account": "sa-missing",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "sa-missing")
+
+		template, err := getRoleTemplate(context.Background(), s, "streaming")
+		require.NoError(t, err)
+		require.Equal(t, "sa-123", template.ServiceAccount)
+		require.Equal(t, 30*time.Minute, template.MaxTTL)
+	})
+
+	t.Run("Inherit request limits", func(t *testing.T) {
+		resp, err := testRoleTemplateWrite(t, b, s, logical.UpdateOperation, "streaming", map[string]interface{}{
+			"bound_cidrs": "10.0.0.0/8",
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.ReadOperation,
+			Path:       "creds/orders",
+			Storage:    s,
+			Connection: &logical.Connection{RemoteAddr: "172.16.0.1"},
+		})
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.ReadOperation,
+			Path:       "creds/orders",
+			Storage:    s,
+			Connection: &logical.Connection{RemoteAddr: "10.1.2.3"},
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+	})
+}
@@ -3,28 +3,179 @@ package backend
 import (
 	"context"
 	"fmt"
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/go-sockaddr"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"strings"
 	"time"
 )
 
+const (
+	credentialTypeAPIKey         = "api_key"
+	credentialTypeSchemaRegistry = "schema_registry"
+	credentialTypeFlink          = "flink"
+	credentialTypeKsqlDB         = "ksqldb"
+	credentialTypeUser           = "user"
+	credentialTypeOAuth          = "oauth"
+	credentialTypeMDSToken       = "mds_token"
+	credentialTypeLDAPUser       = "ldap_user"
+)
+
 type confluentRoleEntry struct {
-	ServiceAccount string        `json:"service_account"`
-	Token          string        `json:"token"`
-	TokenID        string        `json:"token_id"`
-	TTL            time.Duration `json:"ttl"`
-	MaxTTL         time.Duration `json:"max_ttl"`
+	CredentialType        string        `json:"credential_type,omitempty"`
+	ServiceAccount        string        `json:"service_account"`
+	ManagedServiceAccount string        `json:"managed_service_account,omitempty"`
+	Resource              string        `json:"resource,omitempty"`
+	Environment           string        `json:"environment,omitempty"`
+	Token                 string        `json:"token"`
+	TokenID               string        `json:"token_id"`
+	TTL                   time.Duration `json:"ttl"`
+	MaxTTL                time.Duration `json:"max_ttl"`
+
+	SchemaRegistryURL string   `json:"schema_registry_url,omitempty"`
+	SubjectPrefixes   []string `json:"subject_prefixes,omitempty"`
+	SubjectRoleNames  []string `json:"subject_role_names,omitempty"`
+
+	FlinkCloud       string `json:"flink_cloud,omitempty"`
+	FlinkRegion      string `json:"flink_region,omitempty"`
+	FlinkComputePool string `json:"flink_compute_pool,omitempty"`
+
+	KsqlDBEndpoint string `json:"ksqldb_endpoint,omitempty"`
+
+	AllowedUserIDs []string `json:"allowed_user_ids,omitempty"`
+
+	IdentityPoolID     string                 `json:"identity_pool_id,omitempty"`
+	IdentityPoolFilter string                 `json:"identity_pool_filter,omitempty"`
+	IdentityClaim      string                 `json:"identity_claim,omitempty"`
+	Subject            string                 `json:"subject,omitempty"`
+	Claims             map[string]interface{} `json:"claims,omitempty"`
+
+	Principal        string   `json:"principal,omitempty"`
+	LDAPPassword     string   `json:"ldap_password,omitempty"`
+	RoleBindings     []string `json:"role_bindings,omitempty"`
+	BootstrapServers string   `json:"bootstrap_servers,omitempty"`
+
+	AllowRenewalOnDrift bool `json:"allow_renewal_on_drift,omitempty"`
+
+	AllowedServiceAccounts []string `json:"allowed_service_accounts,omitempty"`
+	KeyDisplayName         string   `json:"key_display_name,omitempty"`
+
+	BoundCIDRs            []*sockaddr.SockAddrMarshaler `json:"bound_cidrs,omitempty"`
+	AllowedEntityIDs      []string                      `json:"allowed_entity_ids,omitempty"`
+	AllowedGroups         []string                      `json:"allowed_groups,omitempty"`
+	AllowedMountAccessors []string                      `json:"allowed_mount_accessors,omitempty"`
+
+	RequireApproval bool          `json:"require_approval,omitempty"`
+	ApprovalTTL     time.Duration `json:"approval_ttl,omitempty"`
+	ApprovalWindow  time.Duration `json:"approval_window,omitempty"`
+
+	Template    string `json:"template,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// credentialType returns the kind of credential the role issues. Roles
+// written before credential types were introduced issue plain API keys.
+func (r *confluentRoleEntry) credentialType() string {
+	if r.CredentialType == "" {
+		return credentialTypeAPIKey
+	}
+	return r.CredentialType
 }
 
 func (r *confluentRoleEntry) toResponseData() map[string]interface{} {
 	respData := map[string]interface{}{
-		"ttl":             r.TTL.Seconds(),
-		"max_ttl":         r.MaxTTL.Seconds(),
-		"service_account": r.ServiceAccount,
+		"ttl":                      r.TTL.Seconds(),
+		"max_ttl":                  r.MaxTTL.Seconds(),
+		"service_account":          r.ServiceAccount,
+		"managed_service_account":  r.ManagedServiceAccount,
+		"resource":                 r.Resource,
+		"environment":              r.Environment,
+		"credential_type":          r.credentialType(),
+		"allow_renewal_on_drift":   r.AllowRenewalOnDrift,
+		"allowed_service_accounts": r.AllowedServiceAccounts,
+		"key_display_name":         r.KeyDisplayName,
+		"bound_cidrs":              r.boundCIDRStrings(),
+		"allowed_entity_ids":       r.AllowedEntityIDs,
+		"allowed_groups":           r.AllowedGroups,
+		"allowed_mount_accessors":  r.AllowedMountAccessors,
+		"require_approval":         r.RequireApproval,
+		"approval_ttl":             r.ApprovalTTL.Seconds(),
+		"approval_window":          r.ApprovalWindow.Seconds(),
+		"template":                 r.Template,
+		"description":              r.Description,
+	}
+
+	switch r.credentialType() {
+	case credentialTypeSchemaRegistry:
+		respData["schema_registry_url"] = r.SchemaRegistryURL
+		respData["subject_prefixes"] = r.SubjectPrefixes
+		respData["subject_role_names"] = r.SubjectRoleNames
+	case credentialTypeFlink:
+		respData["cloud"] = r.FlinkCloud
+		respData["region"] = r.FlinkRegion
+		respData["compute_pool"] = r.FlinkComputePool
+		respData["flink_endpoint"] = r.flinkEndpoint()
+	case credentialTypeKsqlDB:
+		respData["ksqldb_endpoint"] = r.KsqlDBEndpoint
+	case credentialTypeUser:
+		respData["allowed_user_ids"] = r.AllowedUserIDs
+	case credentialTypeOAuth:
+		respData["identity_pool_id"] = r.IdentityPoolID
+		respData["identity_pool_filter"] = r.IdentityPoolFilter
+		respData["identity_claim"] = r.IdentityClaim
+		respData["subject"] = r.Subject
+		respData["claims"] = r.Claims
+	case credentialTypeMDSToken, credentialTypeLDAPUser:
+		respData["principal"] = r.Principal
+		respData["role_bindings"] = r.RoleBindings
+		respData["bootstrap_servers"] = r.BootstrapServers
 	}
 	return respData
 }
 
+// keySpec returns the owner and resource of API keys issued for the role.
+func (r *confluentRoleEntry) keySpec() apiKeySpec {
+	spec := apiKeySpec{
+		Owner:       r.ServiceAccount,
+		Resource:    r.Resource,
+		Environment: r.Environment,
+		DisplayName: r.KeyDisplayName,
+	}
+
+	switch r.credentialType() {
+	case credentialTypeSchemaRegistry:
+		spec.ResourceKind = schemaRegistryResourceKind
+	case credentialTypeFlink:
+		spec.ResourceKind = flinkRegionResourceKind
+	case credentialTypeKsqlDB:
+		spec.ResourceKind = ksqlDBResourceKind
+	}
+	return spec
+}
+
+// connectionInfo returns the details, besides the key itself, that clients
+// need to connect with credentials issued for the role.
+func (r *confluentRoleEntry) connectionInfo(organizationID string, apiKey *confluentApiKey) map[string]interface{} {
+	switch r.credentialType() {
+	case credentialTypeSchemaRegistry:
+		return map[string]interface{}{
+			"schema_registry_url": r.SchemaRegistryURL,
+		}
+	case credentialTypeFlink:
+		return map[string]interface{}{
+			"flink_endpoint": r.flinkEndpoint(),
+			"properties":     r.flinkProperties(organizationID, apiKey),
+		}
+	case credentialTypeKsqlDB:
+		return map[string]interface{}{
+			"ksqldb_endpoint": r.KsqlDBEndpoint,
+			"properties":      r.ksqlDBProperties(apiKey),
+		}
+	}
+	return nil
+}
+
 func pathRole(b *Backend) []*framework.Path {
 	return []*framework.Path{
 		{
@@ -35,11 +186,145 @@ func pathRole(b *Backend) []*framework.Path {
 					Description: "Name of the role",
 					Required:    true,
 				},
+				"credential_type": {
+					Type:          framework.TypeString,
+					Description:   "Kind of credential the role issues: api_key, schema_registry, flink, ksqldb, user or oauth for Confluent Cloud; mds_token or ldap_user for Confluent Platform.",
+					Default:       credentialTypeAPIKey,
+					AllowedValues: []interface{}{credentialTypeAPIKey, credentialTypeSchemaRegistry, credentialTypeFlink, credentialTypeKsqlDB, credentialTypeUser, credentialTypeOAuth, credentialTypeMDSToken, credentialTypeLDAPUser},
+				},
 				"service_account": {
 					Type:        framework.TypeString,
-					Description: "Confluent Cloud service account",
+					Description: "Confluent Cloud service account. May be an identity template, such as {{identity.entity.metadata.confluent_sa}}, resolved when credentials are requested.",
 					Required:    true,
 				},
+				"service_account_name": {
+					Type:        framework.TypeString,
+					Description: "Display name of the Confluent Cloud service account. Resolved to its ID when the role is written. Cannot be used with service_account.",
+				},
+				"managed_service_account": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of a service account managed by Vault under service-account/. Cannot be used with service_account or service_account_name.",
+				},
+				"resource": {
+					Type:        framework.TypeString,
+					Description: "Confluent resource ID, such as a Kafka cluster, that generated API keys are scoped to. If not set, Cloud API keys are generated.",
+				},
+				"environment": {
+					Type:        framework.TypeString,
+					Description: "Confluent environment ID of the resource",
+				},
+				"subject_prefixes": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Schema Registry subject prefixes the role's service account is granted subject_role_names on. Only used by schema_registry roles.",
+				},
+				"subject_role_names": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "RBAC roles granted on subject_prefixes: DeveloperRead, DeveloperWrite or both. Defaults to DeveloperRead.",
+				},
+				"cloud": {
+					Type:        framework.TypeString,
+					Description: "Cloud provider of the Flink region: AWS, GCP or AZURE. Only used by flink roles.",
+				},
+				"region": {
+					Type:        framework.TypeString,
+					Description: "Cloud region of the Flink region, such as us-east-1. Only used by flink roles.",
+				},
+				"compute_pool": {
+					Type:        framework.TypeString,
+					Description: "ID of the Flink compute pool clients should use. Only used by flink roles.",
+				},
+				"allowed_user_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "IDs of the Confluent user accounts that break-glass keys may be issued for. Only used by user roles.",
+				},
+				"subject": {
+					Type:        framework.TypeString,
+					Description: "Subject of the tokens issued for the role. Defaults to vault-role:<name>. Only used by oauth roles.",
+				},
+				"identity_claim": {
+					Type:        framework.TypeString,
+					Description: "Claim Confluent uses as the identity of the token's principal. Defaults to claims.sub. Only used by oauth roles.",
+				},
+				"identity_pool_filter": {
+					Type:        framework.TypeString,
+					Description: "Filter expression tokens must match to use the role's identity pool. Defaults to matching the subject. Only used by oauth roles.",
+				},
+				"claims": {
+					Type:        framework.TypeMap,
+					Description: "Additional claims added to the tokens issued for the role. Only used by oauth roles.",
+				},
+				"principal": {
+					Type:        framework.TypeString,
+					Description: "LDAP user that role_bindings are granted to and credentials are issued for. Only used by mds_token and ldap_user roles.",
+				},
+				"role_bindings": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "RBAC roles granted to the principal on the resource cluster, as RoleName or RoleName:ResourceType:Name[:PREFIXED]. Only used by mds_token and ldap_user roles.",
+				},
+				"ldap_password": {
+					Type:        framework.TypeString,
+					Description: "Password of the LDAP principal. Only used by ldap_user roles.",
+					DisplayAttrs: &framework.DisplayAttributes{
+						Name:      "LDAP Password",
+						Sensitive: true,
+					},
+				},
+				"bootstrap_servers": {
+					Type:        framework.TypeString,
+					Description: "Kafka bootstrap servers returned with the credentials. Only used by mds_token and ldap_user roles.",
+				},
+				"template": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of a role template under role-template/ whose settings the role inherits unless it sets them itself.",
+				},
+				"description": {
+					Type:        framework.TypeString,
+					Description: "Description of the role",
+				},
+				"allow_renewal_on_drift": {
+					Type:        framework.TypeBool,
+					Description: "Renew leases even if the role's service account or resource changed since the key was issued.",
+				},
+				"allowed_service_accounts": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Service accounts, or glob patterns of them, that a templated service_account may resolve to. Required when service_account is an identity template.",
+				},
+				"key_display_name": {
+					Type:        framework.TypeString,
+					Description: "Display name of the API keys issued for the role. May be an identity template. Defaults to \"Vault generated token\".",
+				},
+				"bound_cidrs": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "CIDR blocks that credential requests must come from.",
+				},
+				"allowed_entity_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "IDs of the entities allowed to request credentials.",
+				},
+				"allowed_groups": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Names or IDs of identity groups whose members are allowed to request credentials.",
+				},
+				"allowed_mount_accessors": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Accessors of auth mounts the requesting entity must have an alias on. This constrains the entity, not the auth mount the request's token was issued by.",
+				},
+				"require_approval": {
+					Type:        framework.TypeBool,
+					Description: "Require a second entity to approve each credentials request through approvals/<id>/approve.",
+				},
+				"approval_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long a credentials request waits for approval. Defaults to 1h.",
+				},
+				"approval_window": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long an approved credentials request can be used. Defaults to 5m.",
+				},
+				"skip_validation": {
+					Type:        framework.TypeBool,
+					Description: "Do not check that the service account, resource and environment exist in Confluent when writing the role.",
+				},
 				"ttl": {
 					Type:        framework.TypeDurationSecond,
 					Description: "Default lease for generated credentials. If not set or set to 0, will use system default.",
@@ -67,6 +352,23 @@ func pathRole(b *Backend) []*framework.Path {
 			HelpSynopsis:    pathRoleHelpSynopsis,
 			HelpDescription: pathRoleHelpDescription,
 		},
+		{
+			Pattern: "role/" + framework.GenericNameRegex("name") + "/effective",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the role",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathRolesEffectiveRead,
+				},
+			},
+			HelpSynopsis:    pathRoleEffectiveHelpSynopsis,
+			HelpDescription: pathRoleEffectiveHelpDescription,
+		},
 		{
 			Pattern: "role/?$",
 			Operations: map[logical.Operation]framework.OperationHandler{
@@ -94,12 +396,45 @@ const (
 	pathRoleHelpDescription = `
 This path allows you to read and write roles used to generate Confluent API keys.
 You can configure a role to manage a service accounts tokens by setting the Service Account field.
+`
+	pathRoleEffectiveHelpSynopsis    = `Read the configuration of a role merged with its template.`
+	pathRoleEffectiveHelpDescription = `
+This path returns the settings credentials are issued with: the role's own
+settings, with any it leaves unset taken from its role template.
 `
 	pathRoleListHelpSynopsis    = `List the existing roles in Confluent backend`
 	pathRoleListHelpDescription = `Roles will be listed by the role name.`
 )
 
+// getRole returns the effective configuration of a role, with the settings
+// it leaves unset taken from its template.
 func (b *Backend) getRole(ctx context.Context, s logical.Storage, name string) (*confluentRoleEntry, error) {
+	role, err := getRoleEntry(ctx, s, name)
+	if err != nil || role == nil {
+		return role, err
+	}
+	return resolveRole(ctx, s, role)
+}
+
+// resolveRole merges the role with its template.
+func resolveRole(ctx context.Context, s logical.Storage, role *confluentRoleEntry) (*confluentRoleEntry, error) {
+	if role.Template == "" {
+		return role, nil
+	}
+
+	template, err := getRoleTemplate(ctx, s, role.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	if template == nil {
+		return nil, fmt.Errorf("role template %q does not exist", role.Template)
+	}
+	return role.withTemplate(template), nil
+}
+
+// getRoleEntry returns the role as stored, without its template applied.
+func getRoleEntry(ctx context.Context, s logical.Storage, name string) (*confluentRoleEntry, error) {
 	if name == "" {
 		return nil, fmt.Errorf("missing role name")
 	}
@@ -122,6 +457,21 @@ func (b *Backend) getRole(ctx context.Context, s logical.Storage, name string) (
 }
 
 func (b *Backend) pathRolesRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entry, err := getRoleEntry(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: entry.toResponseData(),
+	}, nil
+}
+
+func (b *Backend) pathRolesEffectiveRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	entry, err := b.getRole(ctx, req.Storage, d.Get("name").(string))
 	if err != nil {
 		return nil, err
@@ -159,21 +509,186 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, d *f
 		return logical.ErrorResponse("missing role name"), nil
 	}
 
-	roleEntry, err := b.getRole(ctx, req.Storage, name.(string))
+	roleEntry, err := getRoleEntry(ctx, req.Storage, name.(string))
 	if err != nil {
 		return nil, err
 	}
 
+	var previous *confluentRoleEntry
 	if roleEntry == nil {
 		roleEntry = &confluentRoleEntry{}
+	} else {
+		existing := *roleEntry
+		previous = &existing
+	}
+
+	template, resp, err := b.buildRole(ctx, req, d, roleEntry, req.Operation == logical.CreateOperation)
+	if resp != nil || err != nil {
+		return resp, err
 	}
 
-	createOperation := req.Operation == logical.CreateOperation
+	change := roleChangeUpdate
+	if previous == nil {
+		change = roleChangeCreate
+	}
+	return b.saveRole(ctx, req, name.(string), previous, roleEntry, template, !d.Get("skip_validation").(bool), &roleVersion{Change: change})
+}
+
+// buildRole sets the fields of roleEntry from the request, applying defaults
+// to fields that are not set on create, and returns the role's template.
+func (b *Backend) buildRole(ctx context.Context, req *logical.Request, d *framework.FieldData, roleEntry *confluentRoleEntry, createOperation bool) (*roleTemplate, *logical.Response, error) {
+	if credentialType, ok := d.GetOk("credential_type"); ok {
+		roleEntry.CredentialType = credentialType.(string)
+	} else if createOperation {
+		roleEntry.CredentialType = d.Get("credential_type").(string)
+	}
+
+	if err := checkConnectionType(ctx, req.Storage, roleEntry.credentialType()); err != nil {
+		return nil, logical.ErrorResponse(err.Error()), nil
+	}
+
+	if templateName, ok := d.GetOk("template"); ok {
+		roleEntry.Template = templateName.(string)
+	}
+
+	var template *roleTemplate
+	if roleEntry.Template != "" {
+		var err error
+		template, err = getRoleTemplate(ctx, req.Storage, roleEntry.Template)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if template == nil {
+			return nil, logical.ErrorResponse("role template %q does not exist", roleEntry.Template), nil
+		}
+	}
+
+	if description, ok := d.GetOk("description"); ok {
+		roleEntry.Description = description.(string)
+	}
+
+	serviceAccount, byID := d.GetOk("service_account")
+	serviceAccountName, byName := d.GetOk("service_account_name")
+	managedName, byManaged := d.GetOk("managed_service_account")
+	if (byID && byName) || (byID && byManaged) || (byName && byManaged) {
+		return nil, logical.ErrorResponse("only one of service_account, service_account_name or managed_service_account can be set"), nil
+	}
 
-	if serviceAccount, ok := d.GetOk("service_account"); ok {
+	if byID {
 		roleEntry.ServiceAccount = serviceAccount.(string)
-	} else if !ok && createOperation {
-		return nil, fmt.Errorf("missing service account in role")
+		roleEntry.ManagedServiceAccount = ""
+	} else if byManaged {
+		account, err := getManagedServiceAccount(ctx, req.Storage, managedName.(string))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if account == nil {
+			return nil, logical.ErrorResponse("managed service account %q does not exist", managedName), nil
+		}
+		roleEntry.ServiceAccount = account.ID
+		roleEntry.ManagedServiceAccount = managedName.(string)
+	} else if byName {
+		c, err := b.getClient(ctx, req.Storage)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		id, err := findServiceAccountByName(ctx, c, serviceAccountName.(string))
+		if err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil
+		}
+		roleEntry.ServiceAccount = id
+		roleEntry.ManagedServiceAccount = ""
+	} else if createOperation && roleEntry.requiresServiceAccount() && (template == nil || template.ServiceAccount == "") {
+		return nil, nil, fmt.Errorf("missing service account in role")
+	}
+
+	if resource, ok := d.GetOk("resource"); ok {
+		roleEntry.Resource = resource.(string)
+	}
+
+	if environment, ok := d.GetOk("environment"); ok {
+		roleEntry.Environment = environment.(string)
+	}
+
+	if allowDrift, ok := d.GetOk("allow_renewal_on_drift"); ok {
+		roleEntry.AllowRenewalOnDrift = allowDrift.(bool)
+	}
+
+	if allowed, ok := d.GetOk("allowed_service_accounts"); ok {
+		roleEntry.AllowedServiceAccounts = allowed.([]string)
+	}
+
+	if keyDisplayName, ok := d.GetOk("key_display_name"); ok {
+		roleEntry.KeyDisplayName = keyDisplayName.(string)
+	}
+
+	if boundCIDRs, ok := d.GetOk("bound_cidrs"); ok {
+		parsed, err := parseutil.ParseAddrs(boundCIDRs)
+		if err != nil {
+			return nil, logical.ErrorResponse("invalid bound_cidrs: %s", err), nil
+		}
+		roleEntry.BoundCIDRs = parsed
+	}
+
+	if entityIDs, ok := d.GetOk("allowed_entity_ids"); ok {
+		roleEntry.AllowedEntityIDs = entityIDs.([]string)
+	}
+
+	if groups, ok := d.GetOk("allowed_groups"); ok {
+		roleEntry.AllowedGroups = groups.([]string)
+	}
+
+	if accessors, ok := d.GetOk("allowed_mount_accessors"); ok {
+		roleEntry.AllowedMountAccessors = accessors.([]string)
+	}
+
+	if requireApproval, ok := d.GetOk("require_approval"); ok {
+		roleEntry.RequireApproval = requireApproval.(bool)
+	}
+
+	if approvalTTL, ok := d.GetOk("approval_ttl"); ok {
+		roleEntry.ApprovalTTL = time.Duration(approvalTTL.(int)) * time.Second
+	}
+
+	if approvalWindow, ok := d.GetOk("approval_window"); ok {
+		roleEntry.ApprovalWindow = time.Duration(approvalWindow.(int)) * time.Second
+	}
+
+	switch roleEntry.credentialType() {
+	case credentialTypeAPIKey:
+	case credentialTypeSchemaRegistry:
+		if resp, err := b.updateSchemaRegistryRole(ctx, req, d, roleEntry); resp != nil || err != nil {
+			return nil, resp, err
+		}
+	case credentialTypeFlink:
+		if resp := updateFlinkRole(d, roleEntry); resp != nil {
+			return nil, resp, nil
+		}
+	case credentialTypeKsqlDB:
+		if resp, err := b.updateKsqlDBRole(ctx, req, d, roleEntry); resp != nil || err != nil {
+			return nil, resp, err
+		}
+	case credentialTypeUser:
+		if resp := updateUserRole(d, roleEntry); resp != nil {
+			return nil, resp, nil
+		}
+	case credentialTypeOAuth:
+		if resp := updateOAuthRole(d, d.Get("name").(string), roleEntry); resp != nil {
+			return nil, resp, nil
+		}
+	case credentialTypeMDSToken, credentialTypeLDAPUser:
+		if resp := updatePlatformRole(d, roleEntry); resp != nil {
+			return nil, resp, nil
+		}
+	default:
+		return nil, logical.ErrorResponse("unsupported credential_type %q", roleEntry.CredentialType), nil
+	}
+
+	if roleEntry.Environment != "" && roleEntry.Resource == "" {
+		return nil, logical.ErrorResponse("environment cannot be set without resource"), nil
 	}
 
 	if ttlRaw, ok := d.GetOk("ttl"); ok {
@@ -188,26 +703,281 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, d *f
 		roleEntry.MaxTTL = time.Duration(d.Get("max_ttl").(int)) * time.Second
 	}
 
-	if roleEntry.MaxTTL != 0 && roleEntry.TTL > roleEntry.MaxTTL {
+	if roleEntry.credentialType() == credentialTypeUser && roleEntry.withTemplate(template).MaxTTL == 0 {
+		roleEntry.MaxTTL = breakGlassMaxTTL
+	}
+
+	return template, nil, nil
+}
+
+// saveRole checks the role and applies it with applyRole.
+func (b *Backend) saveRole(ctx context.Context, req *logical.Request, name string, previous, roleEntry *confluentRoleEntry, template *roleTemplate, validate bool, version *roleVersion) (*logical.Response, error) {
+	if resp, err := b.checkRole(ctx, req.Storage, roleEntry, template, validate); resp != nil || err != nil {
+		return resp, err
+	}
+	return b.applyRole(ctx, req, name, previous, roleEntry, template, version)
+}
+
+// checkRole checks the settings credentials are issued with, including those
+// the role inherits from its template. With validate set, the role's targets
+// are also looked up in Confluent.
+func (b *Backend) checkRole(ctx context.Context, s logical.Storage, roleEntry *confluentRoleEntry, template *roleTemplate, validate bool) (*logical.Response, error) {
+	effective := roleEntry.withTemplate(template)
+
+	if effective.credentialType() == credentialTypeUser && effective.MaxTTL > breakGlassMaxTTL {
+		return logical.ErrorResponse("max_ttl cannot be greater than %s for user roles", breakGlassMaxTTL), nil
+	}
+
+	if effective.credentialType() == credentialTypeOAuth && effective.MaxTTL > oauthKeyRetention {
+		return logical.ErrorResponse("max_ttl cannot be greater than %s for oauth roles", oauthKeyRetention), nil
+	}
+
+	if effective.MaxTTL != 0 && effective.TTL > effective.MaxTTL {
 		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
 	}
 
-	if err := setRole(ctx, req.Storage, name.(string), roleEntry); err != nil {
+	if effective.ApprovalTTL < 0 || effective.ApprovalWindow < 0 {
+		return logical.ErrorResponse("approval_ttl and approval_window cannot be negative"), nil
+	}
+
+	if resp := checkIdentityTemplates(effective); resp != nil {
+		return resp, nil
+	}
+
+	if validate {
+		if err := b.validateRoleTargets(ctx, s, effective); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+	return nil, nil
+}
+
+// applyRole applies a checked role to Confluent where needed, stores it and
+// records it as version in the role's history. previous is the stored role
+// being replaced, if any.
+func (b *Backend) applyRole(ctx context.Context, req *logical.Request, name string, previous, roleEntry *confluentRoleEntry, template *roleTemplate, version *roleVersion) (*logical.Response, error) {
+	effective := roleEntry.withTemplate(template)
+
+	if effective.credentialType() == credentialTypeOAuth {
+		if err := b.ensureIdentityPool(ctx, req.Storage, name, effective); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		roleEntry.IdentityPoolID = effective.IdentityPoolID
+	}
+
+	if isPlatformCredentialType(effective.credentialType()) {
+		var resolved *confluentRoleEntry
+		if previous != nil {
+			var err error
+			if resolved, err = resolveRole(ctx, req.Storage, previous); err != nil {
+				return nil, err
+			}
+		}
+		if err := b.syncPlatformRoleBindings(ctx, req.Storage, resolved, effective); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if err := setRole(ctx, req.Storage, name, roleEntry); err != nil {
 		return nil, err
 	}
 
+	if err := b.recordRoleVersion(ctx, req, name, previous, roleEntry, version); err != nil {
+		return nil, fmt.Errorf("error recording role history: %w", err)
+	}
+
+	b.sendEvent(ctx, req, eventRoleWrite,
+		"role", name,
+		"credential_type", effective.credentialType(),
+		"owner", effective.ServiceAccount,
+		"resource", effective.Resource)
+
 	return nil, nil
 }
 
-func (b *Backend) pathRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "role/"+d.Get("name").(string))
+// requiresServiceAccount reports whether the role issues credentials owned
+// by a service account.
+func (r *confluentRoleEntry) requiresServiceAccount() bool {
+	switch r.credentialType() {
+	case credentialTypeUser, credentialTypeOAuth, credentialTypeMDSToken, credentialTypeLDAPUser:
+		return false
+	}
+	return true
+}
+
+// checkIdentityTemplates checks the identity templates of the role, and that
+// a templated service account is restricted by allowed_service_accounts.
+func checkIdentityTemplates(roleEntry *confluentRoleEntry) *logical.Response {
+	if isIdentityTemplate(roleEntry.ServiceAccount) {
+		if !roleEntry.requiresServiceAccount() {
+			return logical.ErrorResponse("service_account can only be an identity template for roles issuing service account keys")
+		}
+		if err := validateIdentityTemplate(roleEntry.ServiceAccount); err != nil {
+			return logical.ErrorResponse("invalid service_account template: %s", err)
+		}
+		if len(roleEntry.AllowedServiceAccounts) == 0 {
+			return logical.ErrorResponse("allowed_service_accounts is required when service_account is an identity template")
+		}
+	}
+
+	if isIdentityTemplate(roleEntry.KeyDisplayName) {
+		if err := validateIdentityTemplate(roleEntry.KeyDisplayName); err != nil {
+			return logical.ErrorResponse("invalid key_display_name template: %s", err)
+		}
+	}
+	return nil
+}
+
+// checkConnectionType rejects credential types the configured Confluent
+// deployment cannot issue.
+func checkConnectionType(ctx context.Context, s logical.Storage, credentialType string) error {
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	connectionType := connectionTypeCloud
+	if config != nil {
+		connectionType = config.connectionType()
+	}
+
+	if isPlatformCredentialType(credentialType) != (connectionType == connectionTypePlatform) {
+		return fmt.Errorf("credential_type %q cannot be used with connection_type %q", credentialType, connectionType)
+	}
+	return nil
+}
+
+// validateRoleTargets checks that the role's service account, and its
+// resource and environment if it is scoped to one, exist in Confluent.
+// Validation is skipped until the engine has been configured.
+func (b *Backend) validateRoleTargets(ctx context.Context, s logical.Storage, roleEntry *confluentRoleEntry) error {
+	config, err := getConfig(ctx, s)
 	if err != nil {
-		return nil, fmt.Errorf("error deleting confluent role: %w", err)
+		return err
+	}
+
+	if config == nil || isPlatformCredentialType(roleEntry.credentialType()) {
+		return nil
 	}
 
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if roleEntry.credentialType() == credentialTypeUser {
+		for _, userID := range roleEntry.AllowedUserIDs {
+			if _, err := c.getUser(ctx, userID); err != nil {
+				if isNotFound(err) {
+					return fmt.Errorf("user %q does not exist in the configured organization", userID)
+				}
+				return err
+			}
+		}
+	} else if roleEntry.credentialType() == credentialTypeOAuth {
+		// OAuth roles authenticate through an identity pool instead of a
+		// service account.
+	} else if isIdentityTemplate(roleEntry.ServiceAccount) {
+		// Templated service accounts are only known once credentials are
+		// requested.
+	} else if _, err := c.getServiceAccount(ctx, roleEntry.ServiceAccount); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("service account %q does not exist in the configured organization", roleEntry.ServiceAccount)
+		}
+		return err
+	}
+
+	if roleEntry.Resource == "" {
+		return nil
+	}
+
+	if roleEntry.Environment == "" {
+		return fmt.Errorf("environment is required to validate resource %q", roleEntry.Resource)
+	}
+
+	if err := getEnvironment(ctx, c, roleEntry.Environment); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("environment %q does not exist", roleEntry.Environment)
+		}
+		return err
+	}
+
+	if err := getResource(ctx, c, roleEntry.Resource, roleEntry.Environment); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("resource %q does not exist in environment %q", roleEntry.Resource, roleEntry.Environment)
+		}
+		return err
+	}
+
+	if roleEntry.FlinkComputePool != "" {
+		pool, err := getFlinkComputePool(ctx, c, roleEntry.FlinkComputePool, roleEntry.Environment)
+		if err != nil {
+			if isNotFound(err) {
+				return fmt.Errorf("compute pool %q does not exist in environment %q", roleEntry.FlinkComputePool, roleEntry.Environment)
+			}
+			return err
+		}
+		if !strings.EqualFold(pool.Spec.Cloud, roleEntry.FlinkCloud) || pool.Spec.Region != roleEntry.FlinkRegion {
+			return fmt.Errorf("compute pool %q runs in %s %s, not %s %s", roleEntry.FlinkComputePool,
+				pool.Spec.Cloud, pool.Spec.Region, roleEntry.FlinkCloud, roleEntry.FlinkRegion)
+		}
+	}
+
+	return nil
+}
+
+func (b *Backend) pathRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := b.deleteRole(ctx, req, d.Get("name").(string)); err != nil {
+		return nil, err
+	}
 	return nil, nil
 }
 
+// deleteRole removes the role and the Confluent resources managed for it,
+// and records the deletion in its history.
+func (b *Backend) deleteRole(ctx context.Context, req *logical.Request, name string) error {
+	stored, err := getRoleEntry(ctx, req.Storage, name)
+	if err != nil {
+		return err
+	}
+
+	var roleEntry *confluentRoleEntry
+	if stored != nil {
+		if roleEntry, err = resolveRole(ctx, req.Storage, stored); err != nil {
+			return err
+		}
+	}
+
+	if roleEntry != nil && roleEntry.credentialType() == credentialTypeOAuth {
+		if err := b.deleteIdentityPoolForRole(ctx, req.Storage, roleEntry); err != nil {
+			return err
+		}
+	}
+
+	if roleEntry != nil && isPlatformCredentialType(roleEntry.credentialType()) {
+		if err := b.removePlatformRoleBindings(ctx, req.Storage, roleEntry); err != nil {
+			return err
+		}
+	}
+
+	err = req.Storage.Delete(ctx, "role/"+name)
+	if err != nil {
+		return fmt.Errorf("error deleting confluent role: %w", err)
+	}
+
+	if stored != nil {
+		if err := b.recordRoleVersion(ctx, req, name, stored, nil, &roleVersion{Change: roleChangeDelete}); err != nil {
+			return fmt.Errorf("error recording role history: %w", err)
+		}
+	}
+
+	if roleEntry != nil {
+		b.sendEvent(ctx, req, eventRoleDelete, "role", name)
+	}
+
+	return nil
+}
+
 func (b *Backend) pathRolesList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	entries, err := req.Storage.List(ctx, "role/")
 	if err != nil {
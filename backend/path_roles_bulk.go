@@ -0,0 +1,385 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// roleNameRegex matches the names accepted by role/<name>.
+var roleNameRegex = regexp.MustCompile("^" + framework.GenericNameRegex("name") + "$")
+
+// roleExportReadOnlyFields are fields of a role's response data that are
+// looked up or managed by the backend, and cannot be written.
+var roleExportReadOnlyFields = []string{
+	"flink_endpoint",
+	"identity_pool_id",
+	"schema_registry_url",
+	"ksqldb_endpoint",
+}
+
+// toExportData returns the role as the fields of a role write. Unset fields
+// and secrets are left out.
+func (r *confluentRoleEntry) toExportData() map[string]interface{} {
+	data := r.toResponseData()
+	for _, field := range roleExportReadOnlyFields {
+		delete(data, field)
+	}
+
+	// Roles using a managed service account are written with its name.
+	if r.ManagedServiceAccount != "" {
+		delete(data, "service_account")
+	}
+
+	data["ttl"] = int(r.TTL.Seconds())
+	data["max_ttl"] = int(r.MaxTTL.Seconds())
+	data["approval_ttl"] = int(r.ApprovalTTL.Seconds())
+	data["approval_window"] = int(r.ApprovalWindow.Seconds())
+
+	for field, value := range data {
+		if isEmptyValue(value) {
+			delete(data, field)
+		}
+	}
+	return data
+}
+
+// importedRole is a role of an import document, built and checked but not
+// yet applied.
+type importedRole struct {
+	name     string
+	previous *confluentRoleEntry
+	role     *confluentRoleEntry
+	template *roleTemplate
+}
+
+func pathRolesBulk(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "roles/export",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathRolesExport,
+				},
+			},
+			HelpSynopsis:    pathRolesExportHelpSynopsis,
+			HelpDescription: pathRolesExportHelpDescription,
+		},
+		{
+			Pattern: "roles/import",
+			Fields: map[string]*framework.FieldSchema{
+				"roles": {
+					Type:        framework.TypeMap,
+					Description: "Roles by name, each with the fields of a role write, as returned by roles/export.",
+					Required:    true,
+				},
+				"dry_run": {
+					Type:        framework.TypeBool,
+					Description: "Check the roles and return the changes without applying them.",
+				},
+				"delete_missing": {
+					Type:        framework.TypeBool,
+					Description: "Delete roles that are not in the document.",
+				},
+				"skip_validation": {
+					Type:        framework.TypeBool,
+					Description: "Do not check that the service accounts, resources and environments of the roles exist in Confluent.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathRolesImport,
+				},
+			},
+			HelpSynopsis:    pathRolesImportHelpSynopsis,
+			HelpDescription: pathRolesImportHelpDescription,
+		},
+	}
+}
+
+const (
+	pathRolesExportHelpSynopsis    = `Export all roles as one document.`
+	pathRolesExportHelpDescription = `
+This path returns every role under roles, keyed by name, with the fields of
+a role write. LDAP passwords are not exported. The document can be written
+to roles/import as is.
+`
+	pathRolesImportHelpSynopsis    = `Create, replace and delete roles from one document.`
+	pathRolesImportHelpDescription = `
+This path makes the roles match a document in the format of roles/export.
+Every role is replaced with the fields it is given, and roles missing from
+the document are deleted if delete_missing is set. Stored LDAP passwords are
+kept unless the document sets them.
+
+All roles are checked before any are changed, and the errors of every
+invalid role are returned together. If applying a role fails, the roles
+already changed are restored. With dry_run set, the changes are returned
+without applying them.
+`
+)
+
+func (b *Backend) pathRolesExport(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	names, err := req.Storage.List(ctx, "role/")
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		role, err := getRoleEntry(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil {
+			roles[name] = role.toExportData()
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"roles": roles,
+		},
+	}, nil
+}
+
+func (b *Backend) pathRolesImport(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	document := d.Get("roles").(map[string]interface{})
+	skipValidation := d.Get("skip_validation").(bool)
+
+	existing, err := req.Storage.List(ctx, "role/")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(document))
+	for name := range document {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	schema := pathRole(b)[0].Fields
+	var roleErrors []string
+	var imported []*importedRole
+	for _, name := range names {
+		role, err := b.buildImportedRole(ctx, req, schema, name, document[name], skipValidation)
+		if err != nil {
+			roleErrors = append(roleErrors, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		imported = append(imported, role)
+	}
+
+	if len(roleErrors) > 0 {
+		return logical.ErrorResponse("%d of %d roles are invalid, no roles were changed; %s",
+			len(roleErrors), len(names), strings.Join(roleErrors, "; ")), nil
+	}
+
+	added := []string{}
+	changed := map[string]interface{}{}
+	unchanged := []string{}
+	var changes []*importedRole
+	for _, role := range imported {
+		if role.previous == nil {
+			added = append(added, role.name)
+			changes = append(changes, role)
+			continue
+		}
+
+		diff := diffRoles(role.previous, role.role)
+		if len(diff) == 0 {
+			unchanged = append(unchanged, role.name)
+			continue
+		}
+		changed[role.name] = diffResponseData(diff)
+		changes = append(changes, role)
+	}
+
+	removed := []string{}
+	if d.Get("delete_missing").(bool) {
+		for _, name := range existing {
+			if _, ok := document[name]; !ok {
+				removed = append(removed, name)
+			}
+		}
+	}
+
+	dryRun := d.Get("dry_run").(bool)
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"dry_run":   dryRun,
+			"added":     added,
+			"changed":   changed,
+			"removed":   removed,
+			"unchanged": unchanged,
+		},
+	}
+
+	if dryRun {
+		return resp, nil
+	}
+
+	if err := b.applyImport(ctx, req, changes, removed); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	b.logInfo("imported roles",
+		"added", len(added),
+		"changed", len(changed),
+		"removed", len(removed))
+
+	return resp, nil
+}
+
+// buildImportedRole builds and checks a role of an import document the way a
+// role write would, without applying it.
+func (b *Backend) buildImportedRole(ctx context.Context, req *logical.Request, schema map[string]*framework.FieldSchema, name string, raw interface{}, skipValidation bool) (*importedRole, error) {
+	if !roleNameRegex.MatchString(name) || name != strings.ToLower(name) {
+		return nil, errors.New("invalid role name")
+	}
+
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("role must be an object of role fields")
+	}
+
+	data := map[string]interface{}{"name": name}
+	for field, value := range fields {
+		if _, ok := schema[field]; !ok || field == "name" {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		data[field] = value
+	}
+
+	d := &framework.FieldData{Raw: data, Schema: schema}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	previous, err := getRoleEntry(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Roles are replaced rather than merged, apart from the settings that
+	// are looked up or managed by the backend, and the LDAP password, which
+	// is not exported.
+	role := &confluentRoleEntry{}
+	if previous != nil {
+		role.IdentityPoolID = previous.IdentityPoolID
+		role.LDAPPassword = previous.LDAPPassword
+		role.SchemaRegistryURL = previous.SchemaRegistryURL
+		role.KsqlDBEndpoint = previous.KsqlDBEndpoint
+	}
+
+	template, resp, err := b.buildRole(ctx, req, d, role, true)
+	if err == nil && resp == nil {
+		resp, err = b.checkRole(ctx, req.Storage, role, template, !skipValidation && !d.Get("skip_validation").(bool))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil && resp.IsError() {
+		return nil, resp.Error()
+	}
+
+	return &importedRole{
+		name:     name,
+		previous: previous,
+		role:     role,
+		template: template,
+	}, nil
+}
+
+// applyImport applies the changed roles, then deletes the removed ones. If
+// any step fails, the roles already changed are restored.
+func (b *Backend) applyImport(ctx context.Context, req *logical.Request, changes []*importedRole, removed []string) error {
+	var applied []*importedRole
+
+	fail := func(name string, err error) error {
+		if revertErr := b.revertImport(ctx, req, applied); revertErr != nil {
+			return fmt.Errorf("error importing role %q: %s; restoring the roles already changed also failed: %s", name, err, revertErr)
+		}
+		return fmt.Errorf("error importing role %q: %s; the roles already changed were restored", name, err)
+	}
+
+	for _, role := range changes {
+		change := roleChangeUpdate
+		if role.previous == nil {
+			change = roleChangeCreate
+		}
+
+		resp, err := b.applyRole(ctx, req, role.name, role.previous, role.role, role.template, &roleVersion{Change: change})
+		if err == nil && resp != nil && resp.IsError() {
+			err = resp.Error()
+		}
+		if err != nil {
+			return fail(role.name, err)
+		}
+		applied = append(applied, role)
+	}
+
+	for _, name := range removed {
+		previous, err := getRoleEntry(ctx, req.Storage, name)
+		if err != nil {
+			return fail(name, err)
+		}
+		if previous == nil {
+			continue
+		}
+
+		if err := b.deleteRole(ctx, req, name); err != nil {
+			return fail(name, err)
+		}
+		applied = append(applied, &importedRole{name: name, previous: previous})
+	}
+
+	return nil
+}
+
+// revertImport restores the roles changed by an import to their previous
+// versions, most recent change first.
+func (b *Backend) revertImport(ctx context.Context, req *logical.Request, applied []*importedRole) error {
+	var errs []error
+	for i := len(applied) - 1; i >= 0; i-- {
+		role := applied[i]
+		if role.previous == nil {
+			if err := b.deleteRole(ctx, req, role.name); err != nil {
+				errs = append(errs, fmt.Errorf("role %q: %w", role.name, err))
+			}
+			continue
+		}
+
+		restored := *role.previous
+		current, err := getRoleEntry(ctx, req.Storage, role.name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("role %q: %w", role.name, err))
+			continue
+		}
+
+		// Deleted roles lost their identity pool, and get a new one.
+		if current == nil {
+			restored.IdentityPoolID = ""
+		}
+
+		var template *roleTemplate
+		if restored.Template != "" {
+			template, err = getRoleTemplate(ctx, req.Storage, restored.Template)
+		}
+		if err == nil {
+			var resp *logical.Response
+			resp, err = b.applyRole(ctx, req, role.name, current, &restored, template, &roleVersion{Change: roleChangeRollback})
+			if err == nil && resp != nil && resp.IsError() {
+				err = resp.Error()
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("role %q: %w", role.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
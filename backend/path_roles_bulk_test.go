@@ -0,0 +1,186 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// failingPutStorage fails every write of one storage key.
+type failingPutStorage struct {
+	logical.Storage
+	failKey string
+}
+
+func (s *failingPutStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	if entry.Key == s.failKey {
+		return errors.New("put failed")
+	}
+	return s.Storage.Put(ctx, entry)
+}
+
+func testRolesImport(t *testing.T, b *Backend, s logical.Storage, data map[string]interface{}) *logical.Response {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/import",
+		Data:      data,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	return resp
+}
+
+func TestRolesExportImport(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+		"ttl":             testTTL,
+		"max_ttl":         testMaxTTL,
+	})
+	require.NoError(t, err)
+
+	_, err = testTokenRoleCreate(t, b, s, "payments", map[string]interface{}{
+		"service_account": "sa-456",
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/export",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	roles := resp.Data["roles"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{
+		"credential_type": credentialTypeAPIKey,
+		"service_account": "sa-123",
+		"ttl":             int(testTTL),
+		"max_ttl":         int(testMaxTTL),
+	}, roles["orders"])
+
+	t.Run("Import Export unchanged", func(t *testing.T) {
+		resp := testRolesImport(t, b, s, map[string]interface{}{"roles": roles})
+		require.False(t, resp.IsError())
+		require.Equal(t, []string{"orders", "payments"}, resp.Data["unchanged"])
+		require.Empty(t, resp.Data["added"])
+		require.Empty(t, resp.Data["changed"])
+	})
+
+	t.Run("Dry Run", func(t *testing.T) {
+		resp := testRolesImport(t, b, s, map[string]interface{}{
+			"dry_run":        true,
+			"delete_missing": true,
+			"roles": map[string]interface{}{
+				"orders":   map[string]interface{}{"service_account": "sa-789", "ttl": testTTL, "max_ttl": testMaxTTL},
+				"invoices": map[string]interface{}{"service_account": "sa-123"},
+			},
+		})
+		require.False(t, resp.IsError())
+		require.Equal(t, []string{"invoices"}, resp.Data["added"])
+		require.Equal(t, []string{"payments"}, resp.Data["removed"])
+		require.Equal(t, map[string]interface{}{
+			"service_account": map[string]interface{}{"from": "sa-123", "to": "sa-789"},
+		}, resp.Data["changed"].(map[string]interface{})["orders"])
+
+		role, err := getRoleEntry(context.Background(), s, "orders")
+		require.NoError(t, err)
+		require.Equal(t, "sa-123", role.ServiceAccount)
+
+		role, err = getRoleEntry(context.Background(), s, "invoices")
+		require.NoError(t, err)
+		require.Nil(t, role)
+	})
+
+	t.Run("Import invalid Roles - fail", func(t *testing.T) {
+		resp := testRolesImport(t, b, s, map[string]interface{}{
+			"roles": map[string]interface{}{
+				"orders":   map[string]interface{}{"service_account": "sa-789", "ttl": 7200, "max_ttl": 60},
+				"invoices": map[string]interface{}{"service_account": "sa-123", "colour": "blue"},
+				"refunds":  map[string]interface{}{"service_account": "sa-123"},
+			},
+		})
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "2 of 3 roles are invalid")
+		require.Contains(t, resp.Error().Error(), "orders: ttl cannot be greater than max_ttl")
+		require.Contains(t, resp.Error().Error(), `invoices: unknown field "colour"`)
+
+		role, err := getRoleEntry(context.Background(), s, "refunds")
+		require.NoError(t, err)
+		require.Nil(t, role)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		resp := testRolesImport(t, b, s, map[string]interface{}{
+			"delete_missing": true,
+			"roles": map[string]interface{}{
+				"orders":   map[string]interface{}{"service_account": "sa-789"},
+				"invoices": map[string]interface{}{"service_account": "sa-123"},
+			},
+		})
+		require.False(t, resp.IsError())
+
+		role, err := getRoleEntry(context.Background(), s, "orders")
+		require.NoError(t, err)
+		require.Equal(t, "sa-789", role.ServiceAccount)
+		require.Zero(t, role.TTL)
+
+		role, err = getRoleEntry(context.Background(), s, "invoices")
+		require.NoError(t, err)
+		require.NotNil(t, role)
+
+		role, err = getRoleEntry(context.Background(), s, "payments")
+		require.NoError(t, err)
+		require.Nil(t, role)
+
+		versions, err := roleVersions(context.Background(), s, "orders")
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+	})
+}
+
+func TestRolesImportRevert(t *testing.T) {
+	b, storage := getTestBackend(t)
+	s := &failingPutStorage{Storage: storage, failKey: "role/zebra"}
+
+	_, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+		"service_account": "sa-123",
+	})
+	require.NoError(t, err)
+
+	resp := testRolesImport(t, b, s, map[string]interface{}{
+		"roles": map[string]interface{}{
+			"invoices": map[string]interface{}{"service_account": "sa-123"},
+			"orders":   map[string]interface{}{"service_account": "sa-456"},
+			"zebra":    map[string]interface{}{"service_account": "sa-123"},
+		},
+	})
+	require.True(t, resp.IsError())
+	require.Contains(t, resp.Error().Error(), "were restored")
+
+	role, err := getRoleEntry(context.Background(), s, "orders")
+	require.NoError(t, err)
+	require.Equal(t, "sa-123", role.ServiceAccount)
+
+	role, err = getRoleEntry(context.Background(), s, "invoices")
+	require.NoError(t, err)
+	require.Nil(t, role)
+
+	// Reverted changes stay in the history of the roles.
+	for name, changes := range map[string][]string{
+		"orders":   {roleChangeCreate, roleChangeUpdate, roleChangeRollback},
+		"invoices": {roleChangeCreate, roleChangeDelete},
+	} {
+		versions, err := roleVersions(context.Background(), s, name)
+		require.NoError(t, err)
+		require.Len(t, versions, len(changes), name)
+		for i, version := range versions {
+			v, err := getRoleVersion(context.Background(), s, name, version)
+			require.NoError(t, err)
+			require.Equal(t, changes[i], v.Change, name)
+		}
+	}
+}
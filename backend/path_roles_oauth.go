@@ -0,0 +1,193 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"strings"
+	"time"
+)
+
+const (
+	defaultIdentityClaim = "claims.sub"
+)
+
+// reservedOAuthClaims are set by Vault on every token and cannot be
+// overridden through a role's claims.
+var reservedOAuthClaims = []string{"iss", "sub", "exp", "iat", "nbf", "jti"}
+
+// updateOAuthRole applies the OAuth specific fields of a role write. OAuth
+// roles issue JWTs signed by Vault and map them to a Confluent identity pool
+// instead of issuing API keys.
+func updateOAuthRole(d *framework.FieldData, name string, roleEntry *confluentRoleEntry) *logical.Response {
+	if roleEntry.ServiceAccount != "" {
+		return logical.ErrorResponse("service_account cannot be set for oauth roles")
+	}
+
+	if subject, ok := d.GetOk("subject"); ok {
+		roleEntry.Subject = subject.(string)
+	}
+	if roleEntry.Subject == "" {
+		roleEntry.Subject = "vault-role:" + name
+	}
+
+	if claim, ok := d.GetOk("identity_claim"); ok {
+		roleEntry.IdentityClaim = claim.(string)
+	}
+	if roleEntry.IdentityClaim == "" {
+		roleEntry.IdentityClaim = defaultIdentityClaim
+	}
+
+	if filter, ok := d.GetOk("identity_pool_filter"); ok {
+		roleEntry.IdentityPoolFilter = filter.(string)
+	}
+	if roleEntry.IdentityPoolFilter == "" {
+		roleEntry.IdentityPoolFilter = fmt.Sprintf("claims.sub == %q", roleEntry.Subject)
+	}
+
+	if claims, ok := d.GetOk("claims"); ok {
+		roleEntry.Claims = claims.(map[string]interface{})
+	}
+	for _, claim := range reservedOAuthClaims {
+		if _, ok := roleEntry.Claims[claim]; ok {
+			return logical.ErrorResponse("claims cannot override the %q claim", claim)
+		}
+	}
+
+	if roleEntry.Resource != "" && !strings.HasPrefix(roleEntry.Resource, "lkc-") {
+		return logical.ErrorResponse("resource must be a Kafka cluster (lkc-...) for oauth roles")
+	}
+
+	return nil
+}
+
+// ensureIdentityPool creates the role's identity pool, or updates it to match
+// the role's filter and identity claim.
+func (b *Backend) ensureIdentityPool(ctx context.Context, s logical.Storage, name string, roleEntry *confluentRoleEntry) error {
+	config, err := getOAuthConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return fmt.Errorf("oauth/config must be written before creating oauth roles")
+	}
+
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	pool := identityPool{
+		ID:            roleEntry.IdentityPoolID,
+		DisplayName:   "vault-" + name,
+		Description:   "Vault role " + name,
+		IdentityClaim: roleEntry.IdentityClaim,
+		Filter:        roleEntry.IdentityPoolFilter,
+	}
+
+	if pool.ID != "" {
+		return updateIdentityPool(ctx, c, config.ProviderID, pool)
+	}
+
+	created, err := createIdentityPool(ctx, c, config.ProviderID, pool)
+	if err != nil {
+		return err
+	}
+	roleEntry.IdentityPoolID = created.ID
+	return nil
+}
+
+// deleteIdentityPoolForRole removes the identity pool of an OAuth role.
+func (b *Backend) deleteIdentityPoolForRole(ctx context.Context, s logical.Storage, roleEntry *confluentRoleEntry) error {
+	config, err := getOAuthConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if config == nil || roleEntry.IdentityPoolID == "" {
+		return nil
+	}
+
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteIdentityPool(ctx, c, config.ProviderID, roleEntry.IdentityPoolID); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// createOAuthToken signs a JWT for an OAuth role. Tokens cannot be revoked,
+// so they are returned without a lease and kept short-lived instead.
+func (b *Backend) createOAuthToken(ctx context.Context, req *logical.Request, roleEntry *confluentRoleEntry) (*logical.Response, error) {
+	config, err := getOAuthConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("oauth/config has not been written"), nil
+	}
+
+	ring, err := getOAuthKeyRing(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := roleEntry.TTL
+	if ttl == 0 {
+		ttl = b.System().DefaultLeaseTTL()
+	}
+	if roleEntry.MaxTTL > 0 && ttl > roleEntry.MaxTTL {
+		ttl = roleEntry.MaxTTL
+	}
+	if ttl > oauthKeyRetention {
+		ttl = oauthKeyRetention
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	expiry := now.Add(ttl)
+	token, err := ring.sign(roleEntry.Claims, jwt.Claims{
+		Issuer:    config.Issuer,
+		Subject:   roleEntry.Subject,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(expiry),
+		ID:        id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"token":            token,
+		"token_type":       "Bearer",
+		"expires_at":       expiry.Format(time.RFC3339),
+		"identity_pool_id": roleEntry.IdentityPoolID,
+	}
+	if roleEntry.Resource != "" {
+		data["sasl_extensions"] = map[string]interface{}{
+			"logicalCluster": roleEntry.Resource,
+			"identityPoolId": roleEntry.IdentityPoolID,
+		}
+	}
+
+	b.logInfo("issued OAuth token",
+		"subject", roleEntry.Subject,
+		"identity_pool_id", roleEntry.IdentityPoolID,
+		"jti", id,
+		"expires_at", data["expires_at"])
+
+	return &logical.Response{Data: data}, nil
+}
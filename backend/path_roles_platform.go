@@ -0,0 +1,226 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"strings"
+	"time"
+)
+
+const (
+	mdsKafkaClusterScope = "kafka-cluster"
+
+	mdsPatternLiteral  = "LITERAL"
+	mdsPatternPrefixed = "PREFIXED"
+)
+
+// platformRoleBinding is an RBAC role granted through MDS, written as
+// RoleName or RoleName:ResourceType:Name[:PREFIXED].
+type platformRoleBinding struct {
+	RoleName        string
+	ResourcePattern *mdsResourcePattern
+}
+
+func (b platformRoleBinding) String() string {
+	if b.ResourcePattern == nil {
+		return b.RoleName
+	}
+
+	s := b.RoleName + ":" + b.ResourcePattern.ResourceType + ":" + b.ResourcePattern.Name
+	if b.ResourcePattern.PatternType == mdsPatternPrefixed {
+		s += ":" + mdsPatternPrefixed
+	}
+	return s
+}
+
+func parsePlatformRoleBinding(raw string) (platformRoleBinding, error) {
+	parts := strings.Split(raw, ":")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return platformRoleBinding{RoleName: parts[0]}, nil
+	case len(parts) == 3 || (len(parts) == 4 && strings.EqualFold(parts[3], mdsPatternPrefixed)):
+		for _, part := range parts[:3] {
+			if part == "" {
+				return platformRoleBinding{}, fmt.Errorf("invalid role binding %q", raw)
+			}
+		}
+
+		pattern := &mdsResourcePattern{ResourceType: parts[1], Name: parts[2], PatternType: mdsPatternLiteral}
+		if len(parts) == 4 {
+			pattern.PatternType = mdsPatternPrefixed
+		}
+		return platformRoleBinding{RoleName: parts[0], ResourcePattern: pattern}, nil
+	default:
+		return platformRoleBinding{}, fmt.Errorf("invalid role binding %q, expected RoleName or RoleName:ResourceType:Name[:PREFIXED]", raw)
+	}
+}
+
+// isPlatformCredentialType reports whether the credential type is issued by
+// a Confluent Platform deployment rather than Confluent Cloud.
+func isPlatformCredentialType(credentialType string) bool {
+	return credentialType == credentialTypeMDSToken || credentialType == credentialTypeLDAPUser
+}
+
+// updatePlatformRole applies the Confluent Platform specific fields of a role
+// write. Platform roles bind RBAC roles to an existing LDAP principal through
+// MDS instead of using a service account.
+func updatePlatformRole(d *framework.FieldData, roleEntry *confluentRoleEntry) *logical.Response {
+	if roleEntry.ServiceAccount != "" {
+		return logical.ErrorResponse("service_account cannot be set for %s roles", roleEntry.credentialType())
+	}
+
+	if principal, ok := d.GetOk("principal"); ok {
+		roleEntry.Principal = strings.TrimPrefix(principal.(string), "User:")
+	}
+
+	if roleEntry.Principal == "" {
+		return logical.ErrorResponse("principal is required for %s roles", roleEntry.credentialType())
+	}
+
+	if roleEntry.Resource == "" {
+		return logical.ErrorResponse("resource must be set to the Kafka cluster ID for %s roles", roleEntry.credentialType())
+	}
+
+	if bindings, ok := d.GetOk("role_bindings"); ok {
+		roleEntry.RoleBindings = strutil.RemoveDuplicates(bindings.([]string), false)
+	}
+
+	for _, raw := range roleEntry.RoleBindings {
+		if _, err := parsePlatformRoleBinding(raw); err != nil {
+			return logical.ErrorResponse(err.Error())
+		}
+	}
+
+	if password, ok := d.GetOk("ldap_password"); ok {
+		roleEntry.LDAPPassword = password.(string)
+	}
+
+	if roleEntry.credentialType() == credentialTypeLDAPUser && roleEntry.LDAPPassword == "" {
+		return logical.ErrorResponse("ldap_password is required for %s roles", credentialTypeLDAPUser)
+	}
+
+	if bootstrapServers, ok := d.GetOk("bootstrap_servers"); ok {
+		roleEntry.BootstrapServers = bootstrapServers.(string)
+	}
+
+	return nil
+}
+
+func (r *confluentRoleEntry) mdsScope() mdsScope {
+	return mdsScope{Clusters: map[string]string{mdsKafkaClusterScope: r.Resource}}
+}
+
+// syncPlatformRoleBindings grants the role's bindings to its principal and
+// revokes the bindings the previous version of the role granted that are no
+// longer wanted. New bindings are granted first, so a failure partway through
+// leaves the principal with extra rather than missing permissions.
+func (b *Backend) syncPlatformRoleBindings(ctx context.Context, s logical.Storage, previous, roleEntry *confluentRoleEntry) error {
+	c, err := b.getMDSClient(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	for _, raw := range roleEntry.RoleBindings {
+		binding, err := parsePlatformRoleBinding(raw)
+		if err != nil {
+			return err
+		}
+		if err := addMDSRoleBinding(ctx, c, "User:"+roleEntry.Principal, binding, roleEntry.mdsScope()); err != nil {
+			return err
+		}
+	}
+
+	if previous != nil && isPlatformCredentialType(previous.credentialType()) {
+		samePlace := previous.Principal == roleEntry.Principal && previous.Resource == roleEntry.Resource
+		for _, raw := range previous.RoleBindings {
+			if samePlace && strutil.StrListContains(roleEntry.RoleBindings, raw) {
+				continue
+			}
+
+			binding, err := parsePlatformRoleBinding(raw)
+			if err != nil {
+				return err
+			}
+			if err := removeMDSRoleBinding(ctx, c, "User:"+previous.Principal, binding, previous.mdsScope()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removePlatformRoleBindings revokes every binding granted for the role.
+func (b *Backend) removePlatformRoleBindings(ctx context.Context, s logical.Storage, roleEntry *confluentRoleEntry) error {
+	c, err := b.getMDSClient(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	for _, raw := range roleEntry.RoleBindings {
+		binding, err := parsePlatformRoleBinding(raw)
+		if err != nil {
+			return err
+		}
+		if err := removeMDSRoleBinding(ctx, c, "User:"+roleEntry.Principal, binding, roleEntry.mdsScope()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createPlatformCreds issues credentials for a Confluent Platform role. MDS
+// tokens expire on their own and LDAP users are managed outside of Vault, so
+// neither is returned with a lease. The LDAP password is kept in the role,
+// whose storage is seal-wrapped.
+func (b *Backend) createPlatformCreds(ctx context.Context, req *logical.Request, roleEntry *confluentRoleEntry) (*logical.Response, error) {
+	var data map[string]interface{}
+
+	switch roleEntry.credentialType() {
+	case credentialTypeMDSToken:
+		c, err := b.getMDSClient(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		token, err := impersonateMDSPrincipal(ctx, c, "User:"+roleEntry.Principal)
+		if err != nil {
+			return nil, err
+		}
+
+		data = map[string]interface{}{
+			"principal":  roleEntry.Principal,
+			"token":      token.AuthToken,
+			"token_type": token.TokenType,
+			"expires_at": time.Now().UTC().Add(time.Duration(token.ExpiresIn) * time.Second).Format(time.RFC3339),
+		}
+	case credentialTypeLDAPUser:
+		properties := map[string]interface{}{
+			"security.protocol": "SASL_SSL",
+			"sasl.mechanism":    "PLAIN",
+			"sasl.jaas.config": fmt.Sprintf("org.apache.kafka.common.security.plain.PlainLoginModule required username=%q password=%q;",
+				roleEntry.Principal, roleEntry.LDAPPassword),
+		}
+		if roleEntry.BootstrapServers != "" {
+			properties["bootstrap.servers"] = roleEntry.BootstrapServers
+		}
+
+		data = map[string]interface{}{
+			"username":   roleEntry.Principal,
+			"password":   roleEntry.LDAPPassword,
+			"properties": properties,
+		}
+	}
+
+	if roleEntry.BootstrapServers != "" {
+		data["bootstrap_servers"] = roleEntry.BootstrapServers
+	}
+
+	b.logInfo("issued Confluent Platform credentials",
+		"credential_type", roleEntry.credentialType(),
+		"principal", roleEntry.Principal)
+
+	return &logical.Response{Data: data}, nil
+}
@@ -0,0 +1,188 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePlatformRoleBinding(t *testing.T) {
+	binding, err := parsePlatformRoleBinding("DeveloperRead:Topic:payments.:PREFIXED")
+	require.NoError(t, err)
+	require.Equal(t, "DeveloperRead", binding.RoleName)
+	require.Equal(t, &mdsResourcePattern{ResourceType: "Topic", Name: "payments.", PatternType: mdsPatternPrefixed}, binding.ResourcePattern)
+	require.Equal(t, "DeveloperRead:Topic:payments.:PREFIXED", binding.String())
+
+	binding, err = parsePlatformRoleBinding("Operator")
+	require.NoError(t, err)
+	require.Nil(t, binding.ResourcePattern)
+
+	_, err = parsePlatformRoleBinding("DeveloperRead:Topic")
+	require.Error(t, err)
+}
+
+func TestPlatformRoles(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	logins := 0
+	failGrants := false
+	var granted, revoked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == mdsAuthenticatePath {
+			if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			logins++
+			w.Write([]byte(`{"auth_token": "login-token", "token_type": "Bearer", "expires_in": 3600}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer login-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == mdsImpersonationPath:
+			w.Write([]byte(`{"auth_token": "impersonated", "token_type": "Bearer", "expires_in": 300}`))
+		case r.Method == http.MethodPost && failGrants:
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.Method == http.MethodPost:
+			granted = append(granted, r.URL.Path)
+		case r.Method == http.MethodDelete:
+			revoked = append(revoked, r.URL.Path)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("Create Platform Role on Cloud mount - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"credential_type": credentialTypeMDSToken,
+			"principal":       "orders-app",
+			"resource":        "kafka-123",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"connection_type": connectionTypePlatform,
+		"username":        username,
+		"password":        password,
+		"url":             srv.URL,
+	}))
+
+	t.Run("Create Cloud Role on Platform mount - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "cloud", map[string]interface{}{
+			"service_account": "sa-123",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Create MDS Token Role", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"credential_type": credentialTypeMDSToken,
+			"principal":       "User:orders-app",
+			"resource":        "kafka-123",
+			"role_bindings":   "DeveloperRead:Topic:orders.:PREFIXED,Operator",
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.Equal(t, []string{
+			"/security/1.0/principals/User:orders-app/roles/DeveloperRead/bindings",
+			"/security/1.0/principals/User:orders-app/roles/Operator",
+		}, granted)
+		require.Equal(t, 1, logins)
+	})
+
+	t.Run("Update MDS Token Role bindings", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/orders",
+			Data:      map[string]interface{}{"role_bindings": "DeveloperRead:Topic:orders.:PREFIXED"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.Equal(t, []string{"/security/1.0/principals/User:orders-app/roles/Operator"}, revoked)
+	})
+
+	t.Run("Update MDS Token Role keeps bindings when granting fails", func(t *testing.T) {
+		failGrants = true
+		defer func() { failGrants = false }()
+		revoked = nil
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/orders",
+			Data:      map[string]interface{}{"role_bindings": "DeveloperWrite:Topic:orders.:PREFIXED"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Empty(t, revoked)
+	})
+
+	t.Run("Generate MDS Token", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "orders")
+		require.NoError(t, err)
+		require.Nil(t, resp.Secret)
+		require.Equal(t, "impersonated", resp.Data["token"])
+		require.Equal(t, "orders-app", resp.Data["principal"])
+		require.Equal(t, 1, logins)
+	})
+
+	t.Run("Create LDAP User Role without password - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "ldap", map[string]interface{}{
+			"credential_type": credentialTypeLDAPUser,
+			"principal":       "billing-app",
+			"resource":        "kafka-123",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Generate LDAP User Credentials", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "ldap", map[string]interface{}{
+			"credential_type":   credentialTypeLDAPUser,
+			"principal":         "billing-app",
+			"ldap_password":     "hunter2",
+			"resource":          "kafka-123",
+			"bootstrap_servers": "kafka:9092",
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "role/ldap",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotContains(t, resp.Data, "ldap_password")
+
+		resp, err = testCredentialsRead(b, s, "ldap")
+		require.NoError(t, err)
+		require.Nil(t, resp.Secret)
+		require.Equal(t, "billing-app", resp.Data["username"])
+		require.Equal(t, "hunter2", resp.Data["password"])
+
+		properties := resp.Data["properties"].(map[string]interface{})
+		require.Equal(t, "PLAIN", properties["sasl.mechanism"])
+		require.Contains(t, properties["sasl.jaas.config"], `password="hunter2"`)
+		require.Equal(t, "kafka:9092", properties["bootstrap.servers"])
+	})
+
+	t.Run("LDAP password is seal-wrapped", func(t *testing.T) {
+		require.Contains(t, b.PathsSpecial.SealWrapStorage, "role/*")
+		require.Contains(t, b.PathsSpecial.SealWrapStorage, "role-history/*")
+	})
+}
@@ -0,0 +1,101 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	subjectRoleDeveloperRead  = "DeveloperRead"
+	subjectRoleDeveloperWrite = "DeveloperWrite"
+)
+
+// updateSchemaRegistryRole applies the schema_registry specific fields of a
+// role write. The Schema Registry cluster is looked up from the role's
+// environment, and an explicit resource must be that cluster.
+func (b *Backend) updateSchemaRegistryRole(ctx context.Context, req *logical.Request, d *framework.FieldData, roleEntry *confluentRoleEntry) (*logical.Response, error) {
+	if roleEntry.Environment == "" {
+		return logical.ErrorResponse("environment is required for schema_registry roles"), nil
+	}
+
+	if prefixes, ok := d.GetOk("subject_prefixes"); ok {
+		roleEntry.SubjectPrefixes = prefixes.([]string)
+	}
+
+	if roleNames, ok := d.GetOk("subject_role_names"); ok {
+		roleEntry.SubjectRoleNames = roleNames.([]string)
+	}
+
+	if len(roleEntry.SubjectPrefixes) > 0 && len(roleEntry.SubjectRoleNames) == 0 {
+		roleEntry.SubjectRoleNames = []string{subjectRoleDeveloperRead}
+	}
+
+	for _, roleName := range roleEntry.SubjectRoleNames {
+		if roleName != subjectRoleDeveloperRead && roleName != subjectRoleDeveloperWrite {
+			return logical.ErrorResponse("subject_role_names may only contain %s and %s", subjectRoleDeveloperRead, subjectRoleDeveloperWrite), nil
+		}
+	}
+
+	_, resourceSet := d.GetOk("resource")
+	_, environmentSet := d.GetOk("environment")
+	if !resourceSet && !environmentSet && roleEntry.Resource != "" {
+		return nil, nil
+	}
+
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up Schema Registry cluster: %w", err)
+	}
+
+	cluster, err := getSchemaRegistryCluster(ctx, c, roleEntry.Environment)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if resourceSet && cluster.ID != roleEntry.Resource {
+		return logical.ErrorResponse("resource %q is not the Schema Registry cluster of environment %q, which is %q",
+			roleEntry.Resource, roleEntry.Environment, cluster.ID), nil
+	}
+
+	roleEntry.Resource = cluster.ID
+	roleEntry.SchemaRegistryURL = cluster.Spec.HttpEndpoint
+
+	return nil, nil
+}
+
+// subjectCrnPattern returns the CRN pattern matching subjects with the given
+// prefix in the role's Schema Registry cluster.
+func (r *confluentRoleEntry) subjectCrnPattern(organizationID, prefix string) string {
+	return fmt.Sprintf("crn://confluent.cloud/organization=%s/environment=%s/schema-registry=%s/subject=%s*",
+		organizationID, r.Environment, r.Resource, prefix)
+}
+
+// ensureSubjectBindings grants the role's service account its subject role
+// bindings, creating any that are missing.
+func ensureSubjectBindings(ctx context.Context, c cloudClient, roleEntry *confluentRoleEntry) error {
+	if len(roleEntry.SubjectPrefixes) == 0 {
+		return nil
+	}
+
+	organizationID, err := getOrganizationID(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, prefix := range roleEntry.SubjectPrefixes {
+		for _, roleName := range roleEntry.SubjectRoleNames {
+			err := ensureRoleBinding(ctx, c, roleBinding{
+				Principal:  servicePrincipal(roleEntry.ServiceAccount),
+				RoleName:   roleName,
+				CrnPattern: roleEntry.subjectCrnPattern(organizationID, prefix),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSchemaRegistryRole(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	var bindings []roleBinding
+	var keySpec map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/srcm/v3/clusters" && r.URL.Query().Get("environment") == "env-123":
+			w.Write([]byte(`{"data": [{"id": "lsrc-123", "spec": {"http_endpoint": "https://psrc-123.confluent.cloud"}}]}`))
+		case r.URL.Path == "/org/v2/organizations":
+			w.Write([]byte(`{"data": [{"id": "org-123"}]}`))
+		case r.URL.Path == "/iam/v2/role-bindings" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": bindings})
+		case r.URL.Path == "/iam/v2/role-bindings" && r.Method == http.MethodPost:
+			var binding roleBinding
+			json.NewDecoder(r.Body).Decode(&binding)
+			bindings = append(bindings, binding)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(binding)
+		case r.URL.Path == "/iam/v2/api-keys" && r.Method == http.MethodPost:
+			var key map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&key)
+			keySpec = key["spec"].(map[string]interface{})
+			keySpec["secret"] = "secret"
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "SRKEY", "spec": keySpec})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"username": username,
+		"password": password,
+		"url":      srv.URL,
+	}))
+
+	t.Run("Create Schema Registry Role", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"credential_type":  credentialTypeSchemaRegistry,
+			"service_account":  "sa-123",
+			"environment":      "env-123",
+			"subject_prefixes": "payments.",
+			"skip_validation":  true,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = testTokenRoleRead(t, b, s)
+		require.NoError(t, err)
+		require.Equal(t, "lsrc-123", resp.Data["resource"])
+		require.Equal(t, "https://psrc-123.confluent.cloud", resp.Data["schema_registry_url"])
+		require.Equal(t, []string{subjectRoleDeveloperRead}, resp.Data["subject_role_names"])
+	})
+
+	t.Run("Create Schema Registry Role with resource", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName+"-explicit", map[string]interface{}{
+			"credential_type": credentialTypeSchemaRegistry,
+			"service_account": "sa-123",
+			"environment":     "env-123",
+			"resource":        "lsrc-123",
+			"skip_validation": true,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "role/" + roleName + "-explicit",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "lsrc-123", resp.Data["resource"])
+		require.Equal(t, "https://psrc-123.confluent.cloud", resp.Data["schema_registry_url"])
+	})
+
+	t.Run("Create Schema Registry Role with resource of another environment - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName+"-mismatch", map[string]interface{}{
+			"credential_type": credentialTypeSchemaRegistry,
+			"service_account": "sa-123",
+			"environment":     "env-123",
+			"resource":        "lsrc-456",
+			"skip_validation": true,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "not the Schema Registry cluster")
+	})
+
+	t.Run("Create Schema Registry Role with invalid subject role - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName+"-admin", map[string]interface{}{
+			"credential_type":    credentialTypeSchemaRegistry,
+			"service_account":    "sa-123",
+			"environment":        "env-123",
+			"subject_prefixes":   "payments.",
+			"subject_role_names": "ResourceOwner",
+			"skip_validation":    true,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Generate Schema Registry Credentials", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "creds/" + roleName,
+				Storage:   s,
+			})
+			require.NoError(t, err)
+			require.Equal(t, "SRKEY", resp.Data["api_key"])
+			require.Equal(t, "https://psrc-123.confluent.cloud", resp.Data["schema_registry_url"])
+		}
+
+		require.Equal(t, "lsrc-123", keySpec["resource"].(map[string]interface{})["id"])
+		require.Len(t, bindings, 1)
+		require.Equal(t, "User:sa-123", bindings[0].Principal)
+		require.Equal(t, "crn://confluent.cloud/organization=org-123/environment=env-123/schema-registry=lsrc-123/subject=payments.*", bindings[0].CrnPattern)
+	})
+}
@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	neturl "net/url"
+	"strings"
+)
+
+var flinkClouds = []string{"AWS", "GCP", "AZURE"}
+
+// updateFlinkRole applies the flink specific fields of a role write. Flink
+// keys are scoped to a cloud region within an environment.
+func updateFlinkRole(d *framework.FieldData, roleEntry *confluentRoleEntry) *logical.Response {
+	if cloud, ok := d.GetOk("cloud"); ok {
+		roleEntry.FlinkCloud = strings.ToUpper(cloud.(string))
+	}
+
+	if region, ok := d.GetOk("region"); ok {
+		roleEntry.FlinkRegion = region.(string)
+	}
+
+	if computePool, ok := d.GetOk("compute_pool"); ok {
+		roleEntry.FlinkComputePool = computePool.(string)
+	}
+
+	if roleEntry.Environment == "" {
+		return logical.ErrorResponse("environment is required for flink roles")
+	}
+
+	if !strutil.StrListContains(flinkClouds, roleEntry.FlinkCloud) {
+		return logical.ErrorResponse("cloud must be one of %s for flink roles", strings.Join(flinkClouds, ", "))
+	}
+
+	if roleEntry.FlinkRegion == "" {
+		return logical.ErrorResponse("region is required for flink roles")
+	}
+
+	if roleEntry.FlinkComputePool != "" && !strings.HasPrefix(roleEntry.FlinkComputePool, "lfcp-") {
+		return logical.ErrorResponse("compute_pool must be a Flink compute pool ID (lfcp-...)")
+	}
+
+	roleEntry.Resource = strings.ToLower(roleEntry.FlinkCloud) + "." + roleEntry.FlinkRegion
+	return nil
+}
+
+// flinkEndpoint returns the regional Flink REST endpoint.
+func (r *confluentRoleEntry) flinkEndpoint() string {
+	return fmt.Sprintf("https://flink.%s.%s.confluent.cloud", r.FlinkRegion, strings.ToLower(r.FlinkCloud))
+}
+
+// flinkProperties returns the settings used by the Flink Table API client.
+func (r *confluentRoleEntry) flinkProperties(organizationID string, apiKey *confluentApiKey) map[string]string {
+	properties := map[string]string{
+		"client.cloud":            strings.ToLower(r.FlinkCloud),
+		"client.region":           r.FlinkRegion,
+		"client.organization-id":  organizationID,
+		"client.environment-id":   r.Environment,
+		"client.flink-api-key":    apiKey.ApiKey,
+		"client.flink-api-secret": apiKey.ApiSecret,
+		"client.compute-pool-id":  r.FlinkComputePool,
+	}
+	if r.FlinkComputePool == "" {
+		delete(properties, "client.compute-pool-id")
+	}
+	return properties
+}
+
+// updateKsqlDBRole applies the ksqldb specific fields of a role write. The
+// cluster endpoint is looked up unless validation is skipped.
+func (b *Backend) updateKsqlDBRole(ctx context.Context, req *logical.Request, d *framework.FieldData, roleEntry *confluentRoleEntry) (*logical.Response, error) {
+	if !strings.HasPrefix(roleEntry.Resource, "lksqlc-") {
+		return logical.ErrorResponse("resource must be a ksqlDB cluster ID (lksqlc-...) for ksqldb roles"), nil
+	}
+
+	if roleEntry.Environment == "" {
+		return logical.ErrorResponse("environment is required for ksqldb roles"), nil
+	}
+
+	_, resourceSet := d.GetOk("resource")
+	_, environmentSet := d.GetOk("environment")
+	if roleEntry.KsqlDBEndpoint != "" && !resourceSet && !environmentSet {
+		return nil, nil
+	}
+
+	if d.Get("skip_validation").(bool) {
+		roleEntry.KsqlDBEndpoint = ""
+		return nil, nil
+	}
+
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up ksqlDB cluster: %w", err)
+	}
+
+	cluster, err := getKsqlDBCluster(ctx, c, roleEntry.Resource, roleEntry.Environment)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	roleEntry.KsqlDBEndpoint = cluster.Spec.HttpEndpoint
+	return nil, nil
+}
+
+// ksqlDBProperties returns the connection settings used by the ksqlDB client.
+func (r *confluentRoleEntry) ksqlDBProperties(apiKey *confluentApiKey) map[string]string {
+	properties := map[string]string{
+		"basic_auth_username": apiKey.ApiKey,
+		"basic_auth_password": apiKey.ApiSecret,
+	}
+
+	if u, err := neturl.Parse(r.KsqlDBEndpoint); err == nil && u.Host != "" {
+		port := u.Port()
+		if port == "" {
+			port = "443"
+			if u.Scheme == "http" {
+				port = "80"
+			}
+		}
+		properties["host"] = u.Hostname()
+		properties["port"] = port
+		properties["use_tls"] = fmt.Sprint(u.Scheme == "https")
+	}
+	return properties
+}
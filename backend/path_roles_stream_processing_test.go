@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamProcessingRoles(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	var keyResource map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/ksqldbcm/v2/clusters/lksqlc-123":
+			w.Write([]byte(`{"id": "lksqlc-123", "spec": {"http_endpoint": "https://pksqlc-123.us-east-1.aws.confluent.cloud:443"}}`))
+		case r.URL.Path == "/iam/v2/service-accounts/sa-123", r.URL.Path == "/org/v2/environments/env-123":
+			w.Write([]byte(`{}`))
+		case r.URL.Path == "/org/v2/organizations":
+			w.Write([]byte(`{"data": [{"id": "org-123"}]}`))
+		case r.URL.Path == "/iam/v2/api-keys" && r.Method == http.MethodPost:
+			var key map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&key)
+			spec := key["spec"].(map[string]interface{})
+			keyResource = spec["resource"].(map[string]interface{})
+			spec["secret"] = "secret"
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "KEY", "spec": spec})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"username": username,
+		"password": password,
+		"url":      srv.URL,
+	}))
+
+	t.Run("Create Flink Role without region - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "flink", map[string]interface{}{
+			"credential_type": credentialTypeFlink,
+			"service_account": "sa-123",
+			"environment":     "env-123",
+			"cloud":           "aws",
+			"skip_validation": true,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Generate Flink Credentials", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "flink", map[string]interface{}{
+			"credential_type": credentialTypeFlink,
+			"service_account": "sa-123",
+			"environment":     "env-123",
+			"cloud":           "aws",
+			"region":          "us-east-1",
+			"compute_pool":    "lfcp-123",
+			"skip_validation": true,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = testCredentialsRead(b, s, "flink")
+		require.NoError(t, err)
+		require.Equal(t, "https://flink.us-east-1.aws.confluent.cloud", resp.Data["flink_endpoint"])
+		require.Equal(t, map[string]string{
+			"client.cloud":            "aws",
+			"client.region":           "us-east-1",
+			"client.organization-id":  "org-123",
+			"client.environment-id":   "env-123",
+			"client.compute-pool-id":  "lfcp-123",
+			"client.flink-api-key":    "KEY",
+			"client.flink-api-secret": "secret",
+		}, resp.Data["properties"])
+		require.Equal(t, "aws.us-east-1", keyResource["id"])
+		require.Equal(t, flinkRegionResourceKind, keyResource["kind"])
+	})
+
+	t.Run("Create ksqlDB Role with Kafka cluster - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "ksqldb", map[string]interface{}{
+			"credential_type": credentialTypeKsqlDB,
+			"service_account": "sa-123",
+			"environment":     "env-123",
+			"resource":        "lkc-123",
+			"skip_validation": true,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Generate ksqlDB Credentials", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "ksqldb", map[string]interface{}{
+			"credential_type": credentialTypeKsqlDB,
+			"service_account": "sa-123",
+			"environment":     "env-123",
+			"resource":        "lksqlc-123",
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = testCredentialsRead(b, s, "ksqldb")
+		require.NoError(t, err)
+		require.Equal(t, "https://pksqlc-123.us-east-1.aws.confluent.cloud:443", resp.Data["ksqldb_endpoint"])
+		require.Equal(t, map[string]string{
+			"host":                "pksqlc-123.us-east-1.aws.confluent.cloud",
+			"port":                "443",
+			"use_tls":             "true",
+			"basic_auth_username": "KEY",
+			"basic_auth_password": "secret",
+		}, resp.Data["properties"])
+		require.Equal(t, "lksqlc-123", keyResource["id"])
+	})
+}
+
+// Utility function to generate credentials for a role and return any errors
+func testCredentialsRead(b *Backend, s logical.Storage, name string) (*logical.Response, error) {
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + name,
+		Storage:   s,
+	})
+}
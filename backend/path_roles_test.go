@@ -4,6 +4,9 @@ import (
 	"context"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path"
 	"strconv"
 	"testing"
 )
@@ -46,6 +49,26 @@ func TestUserRole(t *testing.T) {
 		require.Nil(t, resp)
 	})
 
+	t.Run("Create Scoped Role without resource - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName+"-scoped", map[string]interface{}{
+			"service_account": roleName,
+			"environment":     "env-123",
+		})
+
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Create Role with service account ID and name - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName+"-named", map[string]interface{}{
+			"service_account":      roleName,
+			"service_account_name": "payments",
+		})
+
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
 	t.Run("Read User Role", func(t *testing.T) {
 		resp, err := testTokenRoleRead(t, b, s)
 
@@ -148,3 +171,59 @@ func testTokenRoleDelete(t *testing.T, b *Backend, s logical.Storage) (*logical.
 		Storage:   s,
 	})
 }
+
+func TestRoleValidation(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/iam/v2/service-accounts/sa-exists", "/org/v2/environments/env-exists", "/cmk/v2/clusters/lkc-exists":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": "` + path.Base(r.URL.Path) + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"username": username,
+		"password": password,
+		"url":      srv.URL,
+	}))
+
+	tests := map[string]struct {
+		data    map[string]interface{}
+		invalid bool
+	}{
+		"existing service account": {
+			data: map[string]interface{}{"service_account": "sa-exists"},
+		},
+		"missing service account": {
+			data:    map[string]interface{}{"service_account": "sa-missing"},
+			invalid: true,
+		},
+		"missing service account, skipped": {
+			data: map[string]interface{}{"service_account": "sa-missing", "skip_validation": true},
+		},
+		"existing cluster": {
+			data: map[string]interface{}{"service_account": "sa-exists", "resource": "lkc-exists", "environment": "env-exists"},
+		},
+		"missing cluster": {
+			data:    map[string]interface{}{"service_account": "sa-exists", "resource": "lkc-missing", "environment": "env-exists"},
+			invalid: true,
+		},
+		"missing environment": {
+			data:    map[string]interface{}{"service_account": "sa-exists", "resource": "lkc-exists", "environment": "env-missing"},
+			invalid: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := testTokenRoleCreate(t, b, s, roleName, tc.data)
+			require.NoError(t, err)
+			require.Equal(t, tc.invalid, resp.IsError())
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"strings"
+	"time"
+)
+
+const (
+	// breakGlassMaxTTL caps the lifetime of keys owned by user accounts.
+	breakGlassMaxTTL = time.Hour
+)
+
+// updateUserRole applies the user specific fields of a role write. User
+// roles issue break-glass keys owned by one of the allowed user accounts
+// rather than by a service account.
+func updateUserRole(d *framework.FieldData, roleEntry *confluentRoleEntry) *logical.Response {
+	if roleEntry.ServiceAccount != "" {
+		return logical.ErrorResponse("service_account cannot be set for user roles")
+	}
+
+	if userIDs, ok := d.GetOk("allowed_user_ids"); ok {
+		roleEntry.AllowedUserIDs = strutil.RemoveDuplicates(userIDs.([]string), false)
+	}
+
+	if len(roleEntry.AllowedUserIDs) == 0 {
+		return logical.ErrorResponse("allowed_user_ids is required for user roles")
+	}
+
+	for _, userID := range roleEntry.AllowedUserIDs {
+		if !strings.HasPrefix(userID, "u-") {
+			return logical.ErrorResponse("allowed_user_ids must contain user IDs (u-...), got %q", userID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUserRole_BreakGlass(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	var keyOwner map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/iam/v2/users" && r.URL.Query().Get("page_token") == "":
+			w.Write([]byte(`{"metadata": {"next": "/iam/v2/users?page_token=2"}, "data": [{"id": "u-alice", "email": "alice@example.com"}]}`))
+		case r.URL.Path == "/iam/v2/users":
+			w.Write([]byte(`{"metadata": {}, "data": [{"id": "u-bob", "email": "bob@example.com"}]}`))
+		case r.URL.Path == "/iam/v2/api-keys" && r.Method == http.MethodPost:
+			var key map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&key)
+			spec := key["spec"].(map[string]interface{})
+			keyOwner = spec["owner"].(map[string]interface{})
+			spec["secret"] = "secret"
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "KEY", "spec": spec})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	require.NoError(t, testConfigCreate(t, b, s, map[string]interface{}{
+		"username": username,
+		"password": password,
+		"url":      srv.URL,
+	}))
+
+	t.Run("Create User Role above break-glass max_ttl - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "breakglass", map[string]interface{}{
+			"credential_type":  credentialTypeUser,
+			"allowed_user_ids": "u-bob",
+			"max_ttl":          "2h",
+			"skip_validation":  true,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Create User Role", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "breakglass", map[string]interface{}{
+			"credential_type":  credentialTypeUser,
+			"allowed_user_ids": "u-bob",
+			"skip_validation":  true,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		role, err := b.getRole(context.Background(), s, "breakglass")
+		require.NoError(t, err)
+		require.Equal(t, breakGlassMaxTTL, role.MaxTTL)
+	})
+
+	t.Run("Generate Credentials without user - fail", func(t *testing.T) {
+		resp, err := testCredentialsRead(b, s, "breakglass")
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Generate Credentials for disallowed user - fail", func(t *testing.T) {
+		resp, err := testBreakGlassCredentials(b, s, "alice@example.com")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Generate Credentials", func(t *testing.T) {
+		resp, err := testBreakGlassCredentials(b, s, "Bob@example.com")
+		require.NoError(t, err)
+		require.Equal(t, "KEY", resp.Data["api_key"])
+		require.Equal(t, time.Hour, resp.Secret.MaxTTL)
+		require.Equal(t, "u-bob", keyOwner["id"])
+		require.Equal(t, userOwnerKind, keyOwner["kind"])
+	})
+}
+
+func testBreakGlassCredentials(b *Backend, s logical.Storage, email string) (*logical.Response, error) {
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "creds/breakglass",
+		Data:      map[string]interface{}{"user_email": email},
+		Storage:   s,
+	})
+}
@@ -0,0 +1,109 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathServiceAccounts(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "service-accounts/" + framework.GenericNameRegex("id"),
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the Confluent service account",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathServiceAccountsRead,
+				},
+			},
+			HelpSynopsis:    pathServiceAccountsHelpSynopsis,
+			HelpDescription: pathServiceAccountsHelpDescription,
+		},
+		{
+			Pattern: "service-accounts/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathServiceAccountsList,
+				},
+			},
+			HelpSynopsis:    pathServiceAccountsListHelpSynopsis,
+			HelpDescription: pathServiceAccountsListHelpDescription,
+		},
+	}
+}
+
+const (
+	pathServiceAccountsHelpSynopsis    = `Read a Confluent Cloud service account.`
+	pathServiceAccountsHelpDescription = `
+Returns the display name and description of a service account in the
+configured organization, along with the number of API keys it owns.
+`
+	pathServiceAccountsListHelpSynopsis    = `List the Confluent Cloud service accounts in the organization.`
+	pathServiceAccountsListHelpDescription = `Service accounts will be listed by ID, with their display name, description and number of API keys.`
+)
+
+func (b *Backend) pathServiceAccountsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	id := d.Get("id").(string)
+	account, err := c.getServiceAccount(ctx, id)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := countTokens(ctx, c, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":            account.GetId(),
+			"display_name":  account.GetDisplayName(),
+			"description":   account.GetDescription(),
+			"api_key_count": count,
+		},
+	}, nil
+}
+
+func (b *Backend) pathServiceAccountsList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := c.listServiceAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(accounts))
+	keyInfo := make(map[string]interface{}, len(accounts))
+	for _, account := range accounts {
+		count, err := countTokens(ctx, c, account.GetId())
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, account.GetId())
+		keyInfo[account.GetId()] = map[string]interface{}{
+			"display_name":  account.GetDisplayName(),
+			"description":   account.GetDescription(),
+			"api_key_count": count,
+		}
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestServiceAccounts(t *testing.T) {
+	b, s := getTestBackend(t)
+	fake := useFakeCloud(b)
+	fake.addServiceAccount("sa-123", "orders")
+	fake.addServiceAccount("sa-456", "payments")
+
+	for i := 0; i < 2; i++ {
+		_, err := fake.createToken(context.Background(), apiKeySpec{Owner: "sa-123"})
+		require.NoError(t, err)
+	}
+
+	t.Run("List Service Accounts", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "service-accounts/",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"sa-123", "sa-456"}, resp.Data["keys"])
+
+		info := resp.Data["key_info"].(map[string]interface{})
+		require.Equal(t, "orders", info["sa-123"].(map[string]interface{})["display_name"])
+		require.Equal(t, 2, info["sa-123"].(map[string]interface{})["api_key_count"])
+		require.Equal(t, 0, info["sa-456"].(map[string]interface{})["api_key_count"])
+	})
+
+	t.Run("Read Service Account", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "service-accounts/sa-123",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "orders", resp.Data["display_name"])
+		require.Equal(t, 2, resp.Data["api_key_count"])
+	})
+
+	t.Run("Read missing Service Account", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "service-accounts/sa-missing",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+}
@@ -0,0 +1,148 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"time"
+)
+
+const (
+	staticCredentialStoragePrefix = "static-creds/"
+)
+
+// staticCredential is an imported API key that is managed by Vault without a
+// lease. It is deleted from Confluent when it is deleted from Vault.
+type staticCredential struct {
+	ApiKey     string     `json:"api_key"`
+	ApiSecret  string     `json:"api_secret"`
+	Role       string     `json:"role"`
+	Spec       apiKeySpec `json:"spec"`
+	ImportedAt time.Time  `json:"imported_at"`
+}
+
+func pathStaticCredentials(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "static-creds/" + framework.GenericNameRegex("api_key"),
+			Fields: map[string]*framework.FieldSchema{
+				"api_key": {
+					Type:        framework.TypeString,
+					Description: "ID of the imported API key",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathStaticCredentialsRead,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathStaticCredentialsDelete,
+				},
+			},
+			HelpSynopsis:    pathStaticCredentialsHelpSyn,
+			HelpDescription: pathStaticCredentialsHelpDesc,
+		},
+		{
+			Pattern: "static-creds/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathStaticCredentialsList,
+				},
+			},
+			HelpSynopsis:    pathStaticCredentialsListHelpSyn,
+			HelpDescription: pathStaticCredentialsListHelpDesc,
+		},
+	}
+}
+
+const (
+	pathStaticCredentialsHelpSyn  = `Read or revoke an imported static Confluent API Key.`
+	pathStaticCredentialsHelpDesc = `
+Static credentials are API Keys imported with static=true. Reading returns
+the key and secret; deleting removes the key from Confluent.
+`
+	pathStaticCredentialsListHelpSyn  = `List the imported static Confluent API Keys.`
+	pathStaticCredentialsListHelpDesc = `Static credentials will be listed by API Key ID.`
+)
+
+func getStaticCredential(ctx context.Context, s logical.Storage, apiKeyId string) (*staticCredential, error) {
+	entry, err := s.Get(ctx, staticCredentialStoragePrefix+apiKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	cred := new(staticCredential)
+	if err := entry.DecodeJSON(cred); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+func setStaticCredential(ctx context.Context, s logical.Storage, cred *staticCredential) error {
+	entry, err := logical.StorageEntryJSON(staticCredentialStoragePrefix+cred.ApiKey, cred)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func (b *Backend) pathStaticCredentialsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	cred, err := getStaticCredential(ctx, req.Storage, d.Get("api_key").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if cred == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"api_key":     cred.ApiKey,
+			"api_secret":  cred.ApiSecret,
+			"role":        cred.Role,
+			"imported_at": cred.ImportedAt.Format(time.RFC3339),
+		},
+	}, nil
+}
+
+func (b *Backend) pathStaticCredentialsDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	apiKeyId := d.Get("api_key").(string)
+
+	cred, err := getStaticCredential(ctx, req.Storage, apiKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if cred == nil {
+		return nil, nil
+	}
+
+	// Keys already deleted in Confluent are treated as deleted, so they do
+	// not stay registered forever.
+	if err := b.deleteAPIKey(ctx, req.Storage, cred.Role, cred.ApiKey); err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Delete(ctx, staticCredentialStoragePrefix+apiKeyId); err != nil {
+		return nil, fmt.Errorf("error deleting static credential: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathStaticCredentialsList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, staticCredentialStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/helper/cidrutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// boundCIDRStrings returns the role's bound CIDR blocks as strings.
+func (r *confluentRoleEntry) boundCIDRStrings() []string {
+	cidrs := make([]string, 0, len(r.BoundCIDRs))
+	for _, cidr := range r.BoundCIDRs {
+		cidrs = append(cidrs, cidr.String())
+	}
+	return cidrs
+}
+
+// hasRequesterConstraints reports whether the role restricts who may request
+// its credentials beyond Vault's ACL policies.
+func (r *confluentRoleEntry) hasRequesterConstraints() bool {
+	return len(r.BoundCIDRs) > 0 || len(r.AllowedEntityIDs) > 0 || len(r.AllowedGroups) > 0 || len(r.AllowedMountAccessors) > 0
+}
+
+// checkRequester enforces the role's bound_cidrs, allowed_entity_ids,
+// allowed_groups and allowed_mount_accessors, returning why the request
+// is denied.
+func (b *Backend) checkRequester(req *logical.Request, r *confluentRoleEntry) error {
+	if !r.hasRequesterConstraints() {
+		return nil
+	}
+
+	if len(r.BoundCIDRs) > 0 {
+		remoteAddr := remoteAddress(req)
+		if remoteAddr == "" {
+			return errors.New("the request has no remote address to check against the role's bound_cidrs")
+		}
+		if !cidrutil.RemoteAddrIsOk(remoteAddr, r.BoundCIDRs) {
+			return fmt.Errorf("remote address %q is not in the role's bound_cidrs", remoteAddr)
+		}
+	}
+
+	if len(r.AllowedEntityIDs) == 0 && len(r.AllowedGroups) == 0 && len(r.AllowedMountAccessors) == 0 {
+		return nil
+	}
+
+	if req.EntityID == "" {
+		return errors.New("the request has no entity to check against the role's allowed entities, groups and alias mount accessors")
+	}
+
+	if len(r.AllowedEntityIDs) > 0 && !strutil.StrListContains(r.AllowedEntityIDs, req.EntityID) {
+		return fmt.Errorf("entity %q is not in the role's allowed_entity_ids", req.EntityID)
+	}
+
+	if len(r.AllowedGroups) > 0 {
+		groups, err := b.System().GroupsForEntity(req.EntityID)
+		if err != nil {
+			return fmt.Errorf("error looking up groups of entity %q: %w", req.EntityID, err)
+		}
+
+		member := false
+		for _, group := range groups {
+			if strutil.StrListContains(r.AllowedGroups, group.ID) || strutil.StrListContains(r.AllowedGroups, group.Name) {
+				member = true
+				break
+			}
+		}
+		if !member {
+			return fmt.Errorf("entity %q is not a member of any of the role's allowed_groups", req.EntityID)
+		}
+	}
+
+	// Vault does not tell secrets engines which auth mount issued the
+	// request's token, so the entity's aliases are checked instead. A token
+	// from any auth mount of an entity with a matching alias passes.
+	if len(r.AllowedMountAccessors) > 0 {
+		entity, err := b.System().EntityInfo(req.EntityID)
+		if err != nil {
+			return fmt.Errorf("error looking up entity %q: %w", req.EntityID, err)
+		}
+
+		aliased := false
+		if entity != nil {
+			for _, alias := range entity.Aliases {
+				if strutil.StrListContains(r.AllowedMountAccessors, alias.MountAccessor) {
+					aliased = true
+					break
+				}
+			}
+		}
+		if !aliased {
+			return fmt.Errorf("entity %q has no alias on any of the role's allowed_mount_accessors", req.EntityID)
+		}
+	}
+
+	return nil
+}
+
+// denyRequester enforces the role's requester constraints, logging and
+// returning the denial if the request does not meet them.
+func (b *Backend) denyRequester(req *logical.Request, roleName string, r *confluentRoleEntry) (*logical.Response, error) {
+	err := b.checkRequester(req, r)
+	if err == nil {
+		return nil, nil
+	}
+
+	b.logWarn("denied credentials request by role constraints",
+		"role", roleName,
+		"reason", err.Error(),
+		"entity_id", req.EntityID,
+		"display_name", req.DisplayName,
+		"remote_address", remoteAddress(req))
+	return logical.ErrorResponse("role %q denied the request: %s", roleName, err), logical.ErrPermissionDenied
+}
@@ -0,0 +1,149 @@
+package backend
+
+import (
+	"context"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRoleRequesterConstraints(t *testing.T) {
+	b, s := getTestBackend(t)
+	useFakeCloud(b)
+
+	sysView := b.System().(*logical.StaticSystemView)
+	sysView.EntityVal = &logical.Entity{
+		ID:   "entity-1",
+		Name: "orders-app",
+		Aliases: []*logical.Alias{
+			{MountAccessor: "auth_approle_123", Name: "orders-app"},
+		},
+	}
+	sysView.GroupsVal = []*logical.Group{
+		{ID: "group-1", Name: "payments"},
+	}
+
+	creds := func(role, entityID, remoteAddr string) (*logical.Response, error) {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + role,
+			Storage:   s,
+			EntityID:  entityID,
+		}
+		if remoteAddr != "" {
+			req.Connection = &logical.Connection{RemoteAddr: remoteAddr}
+		}
+		return b.HandleRequest(context.Background(), req)
+	}
+
+	update := func(d map[string]interface{}) {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/orders",
+			Data:      d,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	}
+
+	t.Run("Create Role with invalid bound_cidrs - fail", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"service_account": "sa-123",
+			"bound_cidrs":     "not-a-cidr",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "bound_cidrs")
+	})
+
+	t.Run("Create Role", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "orders", map[string]interface{}{
+			"service_account":         "sa-123",
+			"bound_cidrs":             "10.0.0.0/8,192.168.1.10",
+			"allowed_entity_ids":      "entity-1",
+			"allowed_groups":          "payments",
+			"allowed_mount_accessors": "auth_approle_123",
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "role/orders",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"10.0.0.0/8", "192.168.1.10"}, resp.Data["bound_cidrs"])
+		require.Equal(t, []string{"payments"}, resp.Data["allowed_groups"])
+	})
+
+	t.Run("Read Credentials", func(t *testing.T) {
+		resp, err := creds("orders", "entity-1", "10.1.2.3")
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.NotEmpty(t, resp.Data["api_key"])
+	})
+
+	t.Run("Read Credentials outside bound_cidrs - fail", func(t *testing.T) {
+		resp, err := creds("orders", "entity-1", "172.16.0.1")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Contains(t, resp.Error().Error(), "not in the role's bound_cidrs")
+
+		resp, err = creds("orders", "entity-1", "")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Contains(t, resp.Error().Error(), "no remote address")
+	})
+
+	t.Run("Read Credentials as other Entity - fail", func(t *testing.T) {
+		resp, err := creds("orders", "entity-2", "10.1.2.3")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Contains(t, resp.Error().Error(), "allowed_entity_ids")
+
+		resp, err = creds("orders", "", "10.1.2.3")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Contains(t, resp.Error().Error(), "no entity")
+	})
+
+	t.Run("Read Credentials outside allowed_groups - fail", func(t *testing.T) {
+		update(map[string]interface{}{
+			"allowed_groups": "group-2,finance",
+		})
+
+		resp, err := creds("orders", "entity-1", "10.1.2.3")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Contains(t, resp.Error().Error(), "allowed_groups")
+
+		update(map[string]interface{}{
+			"allowed_groups": "group-1",
+		})
+
+		resp, err = creds("orders", "entity-1", "10.1.2.3")
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+	})
+
+	t.Run("Read Credentials outside allowed_mount_accessors - fail", func(t *testing.T) {
+		update(map[string]interface{}{
+			"allowed_mount_accessors": "auth_oidc_456",
+		})
+
+		resp, err := creds("orders", "entity-1", "10.1.2.3")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Contains(t, resp.Error().Error(), "allowed_mount_accessors")
+	})
+
+	t.Run("Remove constraints", func(t *testing.T) {
+		update(map[string]interface{}{
+			"bound_cidrs":             "",
+			"allowed_entity_ids":      "",
+			"allowed_groups":          "",
+			"allowed_mount_accessors": "",
+		})
+
+		resp, err := creds("orders", "", "")
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+	})
+}
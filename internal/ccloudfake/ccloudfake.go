@@ -0,0 +1,572 @@
+// Package ccloudfake serves an in-memory fake of the Confluent Cloud IAM API
+// over httptest. It implements the api-keys, service-accounts and
+// role-bindings endpoints of iam/v2 with the published request and response
+// shapes, basic auth and pagination, so the plugin can be tested end to end
+// through the real SDK without network access.
+package ccloudfake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiKeysPath         = "/iam/v2/api-keys"
+	serviceAccountsPath = "/iam/v2/service-accounts"
+	roleBindingsPath    = "/iam/v2/role-bindings"
+
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// ObjectReference refers to another Confluent object, such as the owner or
+// resource of an API key.
+type ObjectReference struct {
+	ID           string `json:"id"`
+	Environment  string `json:"environment,omitempty"`
+	Related      string `json:"related"`
+	ResourceName string `json:"resource_name"`
+	APIVersion   string `json:"api_version,omitempty"`
+	Kind         string `json:"kind,omitempty"`
+}
+
+// Metadata is the object metadata returned by iam/v2.
+type Metadata struct {
+	Self      string     `json:"self"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// APIKeySpec is the spec of an iam.v2.ApiKey.
+type APIKeySpec struct {
+	Secret      string           `json:"secret,omitempty"`
+	DisplayName string           `json:"display_name,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Owner       *ObjectReference `json:"owner,omitempty"`
+	Resource    *ObjectReference `json:"resource,omitempty"`
+}
+
+// APIKey is an iam.v2.ApiKey.
+type APIKey struct {
+	APIVersion string     `json:"api_version"`
+	Kind       string     `json:"kind"`
+	ID         string     `json:"id"`
+	Metadata   Metadata   `json:"metadata"`
+	Spec       APIKeySpec `json:"spec"`
+}
+
+// ServiceAccount is an iam.v2.ServiceAccount.
+type ServiceAccount struct {
+	APIVersion  string   `json:"api_version"`
+	Kind        string   `json:"kind"`
+	ID          string   `json:"id"`
+	Metadata    Metadata `json:"metadata"`
+	DisplayName string   `json:"display_name"`
+	Description string   `json:"description"`
+}
+
+// RoleBinding is an iam.v2.RoleBinding.
+type RoleBinding struct {
+	APIVersion string   `json:"api_version"`
+	Kind       string   `json:"kind"`
+	ID         string   `json:"id"`
+	Metadata   Metadata `json:"metadata"`
+	Principal  string   `json:"principal"`
+	RoleName   string   `json:"role_name"`
+	CrnPattern string   `json:"crn_pattern"`
+}
+
+type listMetadata struct {
+	First string `json:"first"`
+	Next  string `json:"next,omitempty"`
+}
+
+type list struct {
+	APIVersion string        `json:"api_version"`
+	Kind       string        `json:"kind"`
+	Metadata   listMetadata  `json:"metadata"`
+	Data       []interface{} `json:"data"`
+}
+
+type apiError struct {
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Server is a running fake of the Confluent Cloud IAM API.
+type Server struct {
+	*httptest.Server
+
+	// MaxPageSize caps the page size clients can request, so pagination can
+	// be exercised with few objects.
+	MaxPageSize int
+
+	username string
+	password string
+
+	mu              sync.Mutex
+	apiKeys         map[string]*APIKey
+	serviceAccounts map[string]*ServiceAccount
+	roleBindings    map[string]*RoleBinding
+}
+
+// NewServer starts a fake that accepts the given Cloud API key credentials.
+// API keys created through the fake can authenticate as well. Callers must
+// Close the server.
+func NewServer(username, password string) *Server {
+	s := &Server{
+		MaxPageSize:     maxPageSize,
+		username:        username,
+		password:        password,
+		apiKeys:         map[string]*APIKey{},
+		serviceAccounts: map[string]*ServiceAccount{},
+		roleBindings:    map[string]*RoleBinding{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiKeysPath, s.handleAPIKeys)
+	mux.HandleFunc(apiKeysPath+"/", s.handleAPIKey)
+	mux.HandleFunc(serviceAccountsPath, s.handleServiceAccounts)
+	mux.HandleFunc(serviceAccountsPath+"/", s.handleServiceAccount)
+	mux.HandleFunc(roleBindingsPath, s.handleRoleBindings)
+	mux.HandleFunc(roleBindingsPath+"/", s.handleRoleBinding)
+
+	s.Server = httptest.NewServer(s.authenticate(mux))
+	return s
+}
+
+// AddServiceAccount creates a service account and returns its ID.
+func (s *Server) AddServiceAccount(displayName, description string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createServiceAccount(displayName, description).ID
+}
+
+// AddAPIKey creates an API key owned by the service account, scoped to the
+// resource if one is given, and returns its ID and secret.
+func (s *Server) AddAPIKey(owner, resource string) (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spec := APIKeySpec{Owner: &ObjectReference{ID: owner, Kind: "ServiceAccount"}}
+	if resource != "" {
+		spec.Resource = &ObjectReference{ID: resource, Kind: "Cluster"}
+	}
+	key := s.createAPIKey(spec)
+	return key.ID, key.Spec.Secret
+}
+
+// APIKeyIDs returns the IDs of all API keys, sorted.
+func (s *Server) APIKeyIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedKeys(s.apiKeys)
+}
+
+// HasAPIKey reports whether the API key exists.
+func (s *Server) HasAPIKey(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.apiKeys[id]
+	return ok
+}
+
+// RoleBindings returns all role bindings, sorted by ID.
+func (s *Server) RoleBindings() []RoleBinding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bindings []RoleBinding
+	for _, id := range sortedKeys(s.roleBindings) {
+		bindings = append(bindings, *s.roleBindings[id])
+	}
+	return bindings
+}
+
+// authenticate rejects requests without valid basic auth credentials. Every
+// response carries an X-Request-Id header, like Confluent Cloud's.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", newID("req-", 16))
+
+		username, password, ok := r.BasicAuth()
+		if !ok || !s.validCredentials(username, password) {
+			writeError(w, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) validCredentials(username, password string) bool {
+	if username == s.username && password == s.password {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only Cloud API keys, which have no resource, can call the Cloud API.
+	key, ok := s.apiKeys[username]
+	return ok && key.Spec.Resource == nil && key.Spec.Secret == password
+}
+
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		owner := r.URL.Query().Get("spec.owner")
+		resource := r.URL.Query().Get("spec.resource")
+
+		var keys []interface{}
+		for _, id := range sortedKeys(s.apiKeys) {
+			key := s.apiKeys[id]
+			if owner != "" && (key.Spec.Owner == nil || key.Spec.Owner.ID != owner) {
+				continue
+			}
+			if resource != "" && (key.Spec.Resource == nil || key.Spec.Resource.ID != resource) {
+				continue
+			}
+			keys = append(keys, withoutSecret(key))
+		}
+		s.writeList(w, r, "ApiKeyList", keys)
+	case http.MethodPost:
+		var req APIKey
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Spec.Owner == nil || req.Spec.Owner.ID == "" {
+			writeError(w, http.StatusBadRequest, "spec.owner is required")
+			return
+		}
+		if _, ok := s.serviceAccounts[req.Spec.Owner.ID]; !ok && !strings.HasPrefix(req.Spec.Owner.ID, "u-") {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("owner %q does not exist", req.Spec.Owner.ID))
+			return
+		}
+		writeJSON(w, http.StatusAccepted, s.createAPIKey(req.Spec))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := strings.TrimPrefix(r.URL.Path, apiKeysPath+"/")
+	key, ok := s.apiKeys[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("API key %q not found", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, withoutSecret(key))
+	case http.MethodPatch:
+		var req APIKey
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Spec.DisplayName != "" {
+			key.Spec.DisplayName = req.Spec.DisplayName
+		}
+		if req.Spec.Description != "" {
+			key.Spec.Description = req.Spec.Description
+		}
+		now := time.Now().UTC()
+		key.Metadata.UpdatedAt = &now
+		writeJSON(w, http.StatusOK, withoutSecret(key))
+	case http.MethodDelete:
+		delete(s.apiKeys, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		var accounts []interface{}
+		for _, id := range sortedKeys(s.serviceAccounts) {
+			accounts = append(accounts, s.serviceAccounts[id])
+		}
+		s.writeList(w, r, "ServiceAccountList", accounts)
+	case http.MethodPost:
+		var req ServiceAccount
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.DisplayName == "" {
+			writeError(w, http.StatusBadRequest, "display_name is required")
+			return
+		}
+		for _, account := range s.serviceAccounts {
+			if account.DisplayName == req.DisplayName {
+				writeError(w, http.StatusConflict, fmt.Sprintf("service account %q already exists", req.DisplayName))
+				return
+			}
+		}
+		writeJSON(w, http.StatusCreated, s.createServiceAccount(req.DisplayName, req.Description))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleServiceAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := strings.TrimPrefix(r.URL.Path, serviceAccountsPath+"/")
+	account, ok := s.serviceAccounts[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("service account %q not found", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, account)
+	case http.MethodPatch:
+		var req ServiceAccount
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		account.Description = req.Description
+		now := time.Now().UTC()
+		account.Metadata.UpdatedAt = &now
+		writeJSON(w, http.StatusOK, account)
+	case http.MethodDelete:
+		delete(s.serviceAccounts, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleRoleBindings(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		crnPattern := query.Get("crn_pattern")
+		if crnPattern == "" {
+			writeError(w, http.StatusBadRequest, "crn_pattern is required")
+			return
+		}
+
+		var bindings []interface{}
+		for _, id := range sortedKeys(s.roleBindings) {
+			binding := s.roleBindings[id]
+			if binding.CrnPattern != crnPattern {
+				continue
+			}
+			if principal := query.Get("principal"); principal != "" && binding.Principal != principal {
+				continue
+			}
+			if roleName := query.Get("role_name"); roleName != "" && binding.RoleName != roleName {
+				continue
+			}
+			bindings = append(bindings, binding)
+		}
+		s.writeList(w, r, "RoleBindingList", bindings)
+	case http.MethodPost:
+		var req RoleBinding
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Principal == "" || req.RoleName == "" || req.CrnPattern == "" {
+			writeError(w, http.StatusBadRequest, "principal, role_name and crn_pattern are required")
+			return
+		}
+
+		now := time.Now().UTC()
+		binding := &RoleBinding{
+			APIVersion: "iam/v2",
+			Kind:       "RoleBinding",
+			ID:         newID("rb-", 6),
+			Principal:  req.Principal,
+			RoleName:   req.RoleName,
+			CrnPattern: req.CrnPattern,
+		}
+		binding.Metadata = Metadata{Self: s.URL + roleBindingsPath + "/" + binding.ID, CreatedAt: &now}
+		s.roleBindings[binding.ID] = binding
+		writeJSON(w, http.StatusCreated, binding)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleRoleBinding(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := strings.TrimPrefix(r.URL.Path, roleBindingsPath+"/")
+	binding, ok := s.roleBindings[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("role binding %q not found", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, binding)
+	case http.MethodDelete:
+		delete(s.roleBindings, id)
+		writeJSON(w, http.StatusOK, binding)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// createServiceAccount must be called with s.mu held.
+func (s *Server) createServiceAccount(displayName, description string) *ServiceAccount {
+	now := time.Now().UTC()
+	account := &ServiceAccount{
+		APIVersion:  "iam/v2",
+		Kind:        "ServiceAccount",
+		ID:          newID("sa-", 6),
+		DisplayName: displayName,
+		Description: description,
+	}
+	account.Metadata = Metadata{Self: s.URL + serviceAccountsPath + "/" + account.ID, CreatedAt: &now}
+	s.serviceAccounts[account.ID] = account
+	return account
+}
+
+// createAPIKey must be called with s.mu held. The returned key includes its
+// secret, which is only ever sent in the create response.
+func (s *Server) createAPIKey(spec APIKeySpec) *APIKey {
+	now := time.Now().UTC()
+	key := &APIKey{
+		APIVersion: "iam/v2",
+		Kind:       "ApiKey",
+		ID:         strings.ToUpper(newID("", 8)),
+		Spec:       spec,
+	}
+	key.Metadata = Metadata{Self: s.URL + apiKeysPath + "/" + key.ID, CreatedAt: &now}
+	key.Spec.Secret = newID("", 32)
+
+	owner := *spec.Owner
+	owner.APIVersion = "iam/v2"
+	owner.Related = s.URL + serviceAccountsPath + "/" + owner.ID
+	key.Spec.Owner = &owner
+
+	s.apiKeys[key.ID] = key
+	return key
+}
+
+// writeList writes one page of items. The page_token is the offset of the
+// first item on the page.
+func (s *Server) writeList(w http.ResponseWriter, r *http.Request, kind string, items []interface{}) {
+	query := r.URL.Query()
+
+	pageSize := defaultPageSize
+	if raw := query.Get("page_size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 {
+			writeError(w, http.StatusBadRequest, "invalid page_size")
+			return
+		}
+		pageSize = size
+	}
+	if s.MaxPageSize > 0 && pageSize > s.MaxPageSize {
+		pageSize = s.MaxPageSize
+	}
+
+	offset := 0
+	if raw := query.Get("page_token"); raw != "" {
+		var err error
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "invalid page_token")
+			return
+		}
+	}
+
+	first := *r.URL
+	firstQuery := first.Query()
+	firstQuery.Del("page_token")
+	first.RawQuery = firstQuery.Encode()
+
+	resp := list{
+		APIVersion: "iam/v2",
+		Kind:       kind,
+		Metadata:   listMetadata{First: s.URL + first.String()},
+		Data:       []interface{}{},
+	}
+
+	if offset < len(items) {
+		end := offset + pageSize
+		if end > len(items) {
+			end = len(items)
+		}
+		resp.Data = items[offset:end]
+
+		if end < len(items) {
+			next := first
+			nextQuery := next.Query()
+			nextQuery.Set("page_token", strconv.Itoa(end))
+			next.RawQuery = nextQuery.Encode()
+			resp.Metadata.Next = s.URL + next.String()
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func withoutSecret(key *APIKey) APIKey {
+	copied := *key
+	copied.Spec.Secret = ""
+	return copied
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []apiError{{Status: strconv.Itoa(status), Detail: detail}},
+	})
+}
+
+func newID(prefix string, n int) string {
+	b := make([]byte, (n+1)/2)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return prefix + hex.EncodeToString(b)[:n]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
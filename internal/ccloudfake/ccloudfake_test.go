@@ -0,0 +1,56 @@
+package ccloudfake
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer("user", "pass")
+	defer srv.Close()
+	srv.MaxPageSize = 2
+
+	for _, name := range []string{"a", "b", "c"} {
+		srv.AddServiceAccount(name, "")
+	}
+
+	t.Run("Reject bad credentials", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+serviceAccountsPath, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("user", "wrong")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Paginate", func(t *testing.T) {
+		var names []string
+		next := srv.URL + serviceAccountsPath
+		for next != "" {
+			req, err := http.NewRequest(http.MethodGet, next, nil)
+			require.NoError(t, err)
+			req.SetBasicAuth("user", "pass")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			var page struct {
+				Metadata listMetadata     `json:"metadata"`
+				Data     []ServiceAccount `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+			resp.Body.Close()
+
+			require.LessOrEqual(t, len(page.Data), 2)
+			for _, account := range page.Data {
+				names = append(names, account.DisplayName)
+			}
+			next = page.Metadata.Next
+		}
+		require.ElementsMatch(t, []string{"a", "b", "c"}, names)
+	})
+}